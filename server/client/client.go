@@ -0,0 +1,575 @@
+// Package client is a Go client for the websocket game protocol, for tests,
+// bots and tools. It speaks the JSON wire format.
+//
+// A Client greets the server when it is dialed, keeps the room state up to
+// date from snapshots and patches, acknowledges messages so the server can
+// replay them after a reconnect, and answers pings. Every client message has
+// a typed method that waits for the server's ServerAck or ServerError.
+package client
+
+import (
+	"cardgame/game"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultCapabilities are announced in the hello unless Options.Capabilities
+// is set.
+var DefaultCapabilities = []string{"delta", "resume"}
+
+const (
+	// ackInterval is the number of messages after which the client
+	// acknowledges what it received.
+	ackInterval = 16
+
+	// maxReconnectAttempts is the number of times an automatic reconnect is
+	// tried before the client gives up.
+	maxReconnectAttempts = 8
+)
+
+// Options configure a client. The zero value is usable.
+type Options struct {
+	Header       http.Header   // extra handshake headers, e.g. Origin
+	Capabilities []string      // capabilities announced in the hello
+	Timeout      time.Duration // for dialing and waiting for responses, default 10s
+	ReadTimeout  time.Duration // maximum time without any message or ping, default 90s
+
+	// Handler, if set, is called for every server message from the client's
+	// read goroutine instead of delivering it to Messages.
+	Handler func(game.ServerMessage)
+
+	// Reconnect makes the client reconnect as the same player when the
+	// connection drops after joining a room.
+	Reconnect bool
+}
+
+// Error is a ServerError returned for a client message.
+type Error struct {
+	game.ServerError
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// ErrClosed is returned for requests on a closed client.
+var ErrClosed = errors.New("client closed")
+
+// Client is a connection to the server as a single player.
+type Client struct {
+	url  string
+	opts Options
+
+	// Hello is the server's response to the hello sent by Dial.
+	Hello *game.ServerHello
+
+	writeMu sync.Mutex // serializes writes to socket
+
+	// deliverMu is held for reading while a message is delivered to
+	// messages, so that stop can close the channel once no delivery is in
+	// progress.
+	deliverMu sync.RWMutex
+	delivered bool // true once messages is closed, guarded by deliverMu
+
+	mu        sync.Mutex
+	socket    *websocket.Conn
+	requestId int
+	pending   map[string]chan error // request id -> result of the request
+	state     any                   // room state as generic JSON
+	version   int                   // version of state
+	roomId    string
+	session   game.ServerSession
+	lastSeq   int   // sequence number of the last message received
+	acked     int   // sequence number of the last ClientAck sent
+	backoff   int64 // milliseconds to wait before reconnecting, from ServerShutdown
+	closed    bool  // true once Close was called
+	err       error // why the connection ended, if not closed by Close
+	messages  chan game.ServerMessage
+	done      chan struct{} // closed when the client stops
+}
+
+// Dial connects to a websocket endpoint such as ws://host/api/ws/r_abc and
+// greets the server.
+func Dial(url string, opts Options) (*Client, error) {
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.ReadTimeout == 0 {
+		opts.ReadTimeout = 90 * time.Second
+	}
+	if opts.Capabilities == nil {
+		opts.Capabilities = DefaultCapabilities
+	}
+
+	c := &Client{
+		url:      url,
+		opts:     opts,
+		pending:  map[string]chan error{},
+		messages: make(chan game.ServerMessage, 1024),
+		done:     make(chan struct{}),
+	}
+	socket, hello, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	c.Hello = hello
+	c.socket = socket
+	go c.read(socket)
+	return c, nil
+}
+
+// connect opens a new connection and exchanges hellos.
+func (c *Client) connect() (*websocket.Conn, *game.ServerHello, error) {
+	dialer := websocket.Dialer{
+		HandshakeTimeout: c.opts.Timeout,
+		Subprotocols:     []string{game.SubprotocolJSON},
+	}
+	socket, _, err := dialer.Dial(c.url, c.opts.Header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	socket.SetPingHandler(func(data string) error {
+		socket.SetReadDeadline(time.Now().Add(c.opts.ReadTimeout))
+		err := socket.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(c.opts.Timeout))
+		if errors.Is(err, websocket.ErrCloseSent) {
+			return nil
+		}
+		return err
+	})
+
+	if err := c.write(socket, game.ClientHello{
+		ProtocolVersion: game.ProtocolVersion,
+		Capabilities:    c.opts.Capabilities,
+	}, ""); err != nil {
+		socket.Close()
+		return nil, nil, err
+	}
+
+	socket.SetReadDeadline(time.Now().Add(c.opts.Timeout))
+	for {
+		m, err := c.receive(socket)
+		if err != nil {
+			socket.Close()
+			return nil, nil, err
+		}
+		switch m := m.message.(type) {
+		case *game.ServerHello:
+			socket.SetReadDeadline(time.Now().Add(c.opts.ReadTimeout))
+			return socket, m, nil
+		case *game.ServerError:
+			socket.Close()
+			return nil, nil, &Error{*m}
+		}
+	}
+}
+
+// write encodes a client message as JSON with its type and request id.
+func (c *Client) write(socket *websocket.Conn, message game.ClientMessage, requestId string) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+	payload["type"] = message.ClientType()
+	if requestId != "" {
+		payload["requestId"] = requestId
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	socket.SetWriteDeadline(time.Now().Add(c.opts.Timeout))
+	return socket.WriteJSON(payload)
+}
+
+// received is a decoded server message with the envelope fields.
+type received struct {
+	message game.ServerMessage // nil if the type is unknown
+	seq     int
+	room    any
+	patch   game.Patch
+	version int
+}
+
+// receive reads and decodes the next server message.
+func (c *Client) receive(socket *websocket.Conn) (*received, error) {
+	_, data, err := socket.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	var envelope struct {
+		Type    string     `json:"type"`
+		Seq     int        `json:"seq"`
+		Room    any        `json:"room"`
+		Patch   game.Patch `json:"patch"`
+		Version any        `json:"version"` // also the server version in ServerHello
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+
+	r := &received{
+		seq:   envelope.Seq,
+		room:  envelope.Room,
+		patch: envelope.Patch,
+	}
+	// messages of unknown types, e.g. from a newer server, still carry state
+	if typeVal, ok := game.ServerMessageTypes[envelope.Type]; ok {
+		message := reflect.New(reflect.TypeOf(typeVal))
+		if err := json.Unmarshal(data, message.Interface()); err != nil {
+			return nil, err
+		}
+		r.message = message.Interface().(game.ServerMessage)
+	}
+	if version, ok := envelope.Version.(float64); ok {
+		r.version = int(version)
+	}
+	return r, nil
+}
+
+// read handles messages from a connection until it fails or is replaced.
+func (c *Client) read(socket *websocket.Conn) {
+	for {
+		r, err := c.receive(socket)
+		if err != nil {
+			c.dropped(socket, err)
+			return
+		}
+		socket.SetReadDeadline(time.Now().Add(c.opts.ReadTimeout))
+		c.handle(socket, r)
+	}
+}
+
+// handle updates the client with a received message and delivers it.
+func (c *Client) handle(socket *websocket.Conn, r *received) {
+	c.mu.Lock()
+	if r.seq > c.lastSeq {
+		c.lastSeq = r.seq
+	}
+	ack := c.lastSeq - c.acked
+	if ack >= ackInterval {
+		c.acked = c.lastSeq
+	}
+
+	gap := false
+	if r.room != nil {
+		c.state, c.version = r.room, r.version
+	} else if len(r.patch) > 0 {
+		gap = true
+		if r.version == c.version+1 {
+			if state, err := game.ApplyPatch(c.state, r.patch); err == nil {
+				c.state, c.version = state, r.version
+				gap = false
+			}
+		}
+	}
+
+	var result chan error
+	switch m := r.message.(type) {
+	case *game.ServerSession:
+		c.session = *m
+	case *game.ServerShutdown:
+		c.backoff = int64(m.ReconnectAfter)
+	case *game.ServerAck:
+		result = c.pending[m.RequestId]
+		delete(c.pending, m.RequestId)
+	case *game.ServerError:
+		result = c.pending[m.RequestId]
+		delete(c.pending, m.RequestId)
+	}
+	lastSeq := c.lastSeq
+	c.mu.Unlock()
+
+	if ack >= ackInterval {
+		c.write(socket, game.ClientAck{Seq: lastSeq}, "")
+	}
+	if gap {
+		c.write(socket, game.ClientRequestSnapshot{}, "")
+	}
+	if result != nil {
+		if e, ok := r.message.(*game.ServerError); ok {
+			result <- &Error{*e}
+		} else {
+			result <- nil
+		}
+	}
+
+	if r.message == nil {
+		return
+	}
+	if c.opts.Handler != nil {
+		c.opts.Handler(r.message)
+		return
+	}
+	c.deliverMu.RLock()
+	defer c.deliverMu.RUnlock()
+	if c.delivered {
+		return
+	}
+	select {
+	case c.messages <- r.message:
+	case <-c.done:
+	}
+}
+
+// dropped is called when the read loop of socket ends.
+func (c *Client) dropped(socket *websocket.Conn, err error) {
+	c.mu.Lock()
+	if socket != c.socket || c.closed {
+		// replaced by a reconnect, or closed on purpose
+		c.mu.Unlock()
+		return
+	}
+	canReconnect := c.opts.Reconnect && c.session.Token != ""
+	backoff := time.Duration(c.backoff) * time.Millisecond
+	c.mu.Unlock()
+
+	if canReconnect {
+		delay := 250 * time.Millisecond
+		if backoff > delay {
+			delay = backoff
+		}
+		for i := 0; i < maxReconnectAttempts; i++ {
+			select {
+			case <-time.After(delay):
+			case <-c.done:
+				return
+			}
+			if c.Reconnect() == nil {
+				return
+			}
+			if delay *= 2; delay > 5*time.Second {
+				delay = 5 * time.Second
+			}
+		}
+	}
+	c.stop(err)
+}
+
+// stop ends the client, either because Close was called or because the
+// connection failed for good.
+func (c *Client) stop(err error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	c.err = err
+	for id, result := range c.pending {
+		result <- err
+		delete(c.pending, id)
+	}
+	close(c.done)
+	c.mu.Unlock()
+
+	// deliveries in progress return once done is closed
+	c.deliverMu.Lock()
+	c.delivered = true
+	close(c.messages)
+	c.deliverMu.Unlock()
+}
+
+// Reconnect replaces the connection with a new one and resumes the session
+// as the same player. Messages missed in between are replayed by the server,
+// or a snapshot is sent if they are no longer available.
+func (c *Client) Reconnect() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	reconnect := game.ClientReconnect{
+		RoomId:   c.roomId,
+		PlayerId: c.session.PlayerId,
+		Token:    c.session.Token,
+		LastSeq:  c.lastSeq,
+	}
+	old := c.socket
+	c.mu.Unlock()
+	if reconnect.Token == "" {
+		return errors.New("not in a room")
+	}
+
+	socket, _, err := c.connect()
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		socket.Close()
+		return ErrClosed
+	}
+	c.socket = socket
+	c.mu.Unlock()
+	old.Close()
+
+	if err := c.write(socket, reconnect, ""); err != nil {
+		socket.Close()
+		return err
+	}
+
+	// handle the replayed messages here, so the caller sees them before
+	// Reconnect returns
+	for {
+		r, err := c.receive(socket)
+		if err != nil {
+			socket.Close()
+			return err
+		}
+		if e, ok := r.message.(*game.ServerError); ok && e.Request == reconnect.ClientType() {
+			socket.Close()
+			return &Error{*e}
+		}
+		c.handle(socket, r)
+		if _, ok := r.message.(*game.ServerReconnect); ok {
+			break
+		}
+	}
+	go c.read(socket)
+	return nil
+}
+
+// Messages returns the channel server messages are delivered on, unless a
+// Handler is set. It must be drained, or the client stops reading. It is
+// closed when the client stops.
+func (c *Client) Messages() <-chan game.ServerMessage {
+	return c.messages
+}
+
+// Done is closed when the client stops, either by Close or because the
+// connection failed and could not be resumed.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the client stopped, or nil if it was closed by Close or is
+// still running.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close closes the connection without leaving the room, so the player can
+// still be resumed with their session until the server times them out.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	socket := c.socket
+	c.mu.Unlock()
+
+	// stop first, so the read loop does not take the closed socket for a
+	// dropped connection
+	c.stop(nil)
+	c.writeMu.Lock()
+	socket.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	c.writeMu.Unlock()
+	return socket.Close()
+}
+
+// Socket returns the current connection, e.g. to simulate a dropped
+// connection in tests.
+func (c *Client) Socket() *websocket.Conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.socket
+}
+
+// Send sends a message without waiting for the server's response and
+// returns its request id, which is echoed in the ServerAck or ServerError.
+func (c *Client) Send(message game.ClientMessage) (string, error) {
+	id, _, err := c.send(message, false)
+	return id, err
+}
+
+// Do sends a message and waits for the server's ServerAck, returning an
+// *Error if the server responds with a ServerError.
+func (c *Client) Do(message game.ClientMessage) error {
+	id, result, err := c.send(message, true)
+	if err != nil {
+		return err
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(c.opts.Timeout):
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return fmt.Errorf("timed out waiting for a response to %s", message.ClientType())
+	}
+}
+
+func (c *Client) send(message game.ClientMessage, wait bool) (string, chan error, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return "", nil, ErrClosed
+	}
+	c.requestId++
+	id := "q_" + strconv.Itoa(c.requestId)
+	var result chan error
+	if wait {
+		result = make(chan error, 1)
+		c.pending[id] = result
+	}
+	socket := c.socket
+	c.mu.Unlock()
+
+	if err := c.write(socket, message, id); err != nil {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return "", nil, err
+	}
+	return id, result, nil
+}
+
+// PlayerId returns the id of the client's player once it has joined a room.
+func (c *Client) PlayerId() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session.PlayerId
+}
+
+// State returns the current room state as generic JSON and its version.
+func (c *Client) State() (any, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state, c.version
+}
+
+// Room returns the current room state decoded into a game.Room, or nil if
+// the client is not in a room.
+func (c *Client) Room() (*game.Room, error) {
+	state, _ := c.State()
+	if state == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	var r game.Room
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
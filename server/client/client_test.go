@@ -0,0 +1,148 @@
+package client
+
+import (
+	"cardgame/game"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeServer answers the hello and then passes the connection to handle.
+func fakeServer(t *testing.T, handle func(conn *websocket.Conn)) string {
+	t.Helper()
+	upgrader := websocket.Upgrader{Subprotocols: []string{game.SubprotocolJSON}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var hello map[string]any
+		conn.ReadJSON(&hello)
+		conn.WriteJSON(map[string]any{"type": "hello", "protocolVersion": game.ProtocolVersion, "version": "test"})
+		handle(conn)
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestRequests(t *testing.T) {
+	url := fakeServer(t, func(conn *websocket.Conn) {
+		for {
+			var msg map[string]any
+			if conn.ReadJSON(&msg) != nil {
+				return
+			}
+			if msg["type"] == "chat" {
+				conn.WriteJSON(map[string]any{"type": "ack", "action": "chat", "requestId": msg["requestId"]})
+			} else {
+				conn.WriteJSON(map[string]any{"type": "error", "code": game.ErrorNotOwner, "message": "no", "requestId": msg["requestId"]})
+			}
+		}
+	})
+
+	c, err := Dial(url, Options{Handler: func(game.ServerMessage) {}})
+	assert.NoError(t, err)
+	defer c.Close()
+	assert.Equal(t, "test", c.Hello.Version)
+
+	assert.NoError(t, c.Chat("hello"))
+	var e *Error
+	err = c.Start()
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, game.ErrorNotOwner, e.Code)
+	assert.Equal(t, "not_owner: no", err.Error())
+}
+
+func TestState(t *testing.T) {
+	snapshotRequested := make(chan struct{})
+	url := fakeServer(t, func(conn *websocket.Conn) {
+		conn.WriteJSON(map[string]any{"type": "snapshot", "seq": 1, "version": 1, "room": map[string]any{"id": "r_test", "name": "a"}})
+		conn.WriteJSON(map[string]any{"type": "future", "seq": 2, "version": 2, "patch": game.Patch{{Op: "replace", Path: "/name", Value: "b"}}})
+		conn.WriteJSON(map[string]any{"type": "turn", "seq": 3, "version": 4, "patch": game.Patch{{Op: "replace", Path: "/name", Value: "d"}}})
+		for {
+			var msg map[string]any
+			if conn.ReadJSON(&msg) != nil {
+				return
+			}
+			if msg["type"] == "request_snapshot" {
+				close(snapshotRequested)
+			}
+		}
+	})
+
+	c, err := Dial(url, Options{})
+	assert.NoError(t, err)
+	defer c.Close()
+	assert.IsType(t, &game.ServerSnapshot{}, <-c.Messages())
+	assert.IsType(t, &game.ServerTurn{}, <-c.Messages(), "unknown types should be skipped")
+
+	state, version := c.State()
+	assert.Equal(t, 2, version, "patch after a gap should not be applied")
+	assert.Equal(t, "b", state.(map[string]any)["name"])
+	room, err := c.Room()
+	assert.NoError(t, err)
+	assert.Equal(t, "r_test", room.Id)
+
+	select {
+	case <-snapshotRequested:
+	case <-time.After(time.Second):
+		t.Error("should request a snapshot after a gap")
+	}
+}
+
+func TestClosed(t *testing.T) {
+	url := fakeServer(t, func(conn *websocket.Conn) {})
+
+	c, err := Dial(url, Options{})
+	assert.NoError(t, err)
+	<-c.Done()
+	assert.Error(t, c.Err(), "should report why the connection ended")
+	_, ok := <-c.Messages()
+	assert.False(t, ok)
+	assert.ErrorIs(t, c.Chat("hello"), ErrClosed)
+}
+
+func TestTimeout(t *testing.T) {
+	url := fakeServer(t, func(conn *websocket.Conn) {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+
+	c, err := Dial(url, Options{Timeout: 100 * time.Millisecond})
+	assert.NoError(t, err)
+	defer c.Close()
+	assert.ErrorContains(t, c.Chat("hello"), "timed out")
+	c.mu.Lock()
+	assert.Empty(t, c.pending, "timed out requests should be forgotten")
+	c.mu.Unlock()
+}
+
+func TestCloseWhileReceiving(t *testing.T) {
+	url := fakeServer(t, func(conn *websocket.Conn) {
+		for i := 1; ; i++ {
+			if conn.WriteJSON(map[string]any{"type": "turn", "seq": i, "playerId": "p_1"}) != nil {
+				return
+			}
+		}
+	})
+
+	for i := 0; i < 20; i++ {
+		c, err := Dial(url, Options{})
+		assert.NoError(t, err)
+		<-c.Messages()
+		assert.NoError(t, c.Close())
+		for range c.Messages() {
+			// drained until closed
+		}
+	}
+}
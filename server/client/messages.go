@@ -0,0 +1,120 @@
+package client
+
+import "cardgame/game"
+
+// Typed methods for the client messages. Unless noted otherwise, they wait
+// for the server's response and return an *Error if the message was
+// rejected. ClientHello is sent by Dial and ClientReconnect by Reconnect.
+
+// Join joins a room. password is ignored by public rooms, and name may be ""
+// to keep the generated name. The session needed to reconnect is stored
+// when the server accepts the join.
+func (c *Client) Join(roomId, password, name string) error {
+	err := c.Do(game.ClientJoin{RoomId: roomId, Password: password, Name: name})
+	if err == nil {
+		c.mu.Lock()
+		c.roomId = roomId
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// Leave leaves the room.
+func (c *Client) Leave() error {
+	err := c.Do(game.ClientLeave{})
+	if err == nil {
+		c.mu.Lock()
+		c.roomId = ""
+		c.session = game.ServerSession{}
+		c.state, c.version = nil, 0
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// ChangeDetails changes the room details. Only the owner may do this.
+func (c *Client) ChangeDetails(details game.ClientChangeDetails) error {
+	return c.Do(details)
+}
+
+// Kick kicks a player, and bans them from rejoining if ban is set.
+func (c *Client) Kick(playerId string, ban bool) error {
+	return c.Do(game.ClientKick{Id: playerId, Ban: ban})
+}
+
+// VoteKick votes to kick a player while the owner is absent.
+func (c *Client) VoteKick(playerId string) error {
+	return c.Do(game.ClientVoteKick{Id: playerId})
+}
+
+// TransferOwnership makes another player the owner of the room.
+func (c *Client) TransferOwnership(playerId string) error {
+	return c.Do(game.ClientTransferOwnership{Id: playerId})
+}
+
+// Ready marks the player as ready or not ready to start.
+func (c *Client) Ready(ready bool) error {
+	return c.Do(game.ClientReady{Ready: ready})
+}
+
+// Start starts the game.
+func (c *Client) Start() error {
+	return c.Do(game.ClientStart{})
+}
+
+// Pause pauses the game.
+func (c *Client) Pause() error {
+	return c.Do(game.ClientPause{})
+}
+
+// Resume resumes a paused game.
+func (c *Client) Resume() error {
+	return c.Do(game.ClientResume{})
+}
+
+// Draw draws a card.
+func (c *Client) Draw() error {
+	return c.Do(game.ClientDraw{})
+}
+
+// SendCard sends the player's top card to another player.
+func (c *Client) SendCard(recipientId string) error {
+	return c.Do(game.ClientSend{RecipientId: recipientId})
+}
+
+// Chat sends a public chat message.
+func (c *Client) Chat(message string) error {
+	return c.Do(game.ClientChat{Message: message})
+}
+
+// Whisper sends a private chat message to another player.
+func (c *Client) Whisper(recipientId, message string) error {
+	return c.Do(game.ClientChat{Message: message, RecipientId: &recipientId})
+}
+
+// DeleteChat deletes a chat message.
+func (c *Client) DeleteChat(messageId string) error {
+	return c.Do(game.ClientDeleteChat{Id: messageId})
+}
+
+// Mute mutes a player in the chat for the given number of seconds, or
+// unmutes them if seconds is 0.
+func (c *Client) Mute(playerId string, seconds int) error {
+	return c.Do(game.ClientMute{Id: playerId, Duration: seconds})
+}
+
+// RequestSnapshot asks the server for the full room state.
+func (c *Client) RequestSnapshot() error {
+	return c.Do(game.ClientRequestSnapshot{})
+}
+
+// Ack acknowledges all messages received so far. The client does this
+// periodically by itself. The server does not respond to acks.
+func (c *Client) Ack() error {
+	c.mu.Lock()
+	c.acked = c.lastSeq
+	seq := c.lastSeq
+	c.mu.Unlock()
+	_, err := c.Send(game.ClientAck{Seq: seq})
+	return err
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"cardgame/game"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RoomURL returns the websocket URL for a room on the server with the given
+// base URL, e.g. http://localhost:8080.
+func RoomURL(server, roomId string) string {
+	return "ws" + strings.TrimPrefix(strings.TrimSuffix(server, "/"), "http") + "/api/ws/" + roomId
+}
+
+// CreateRoom creates a room with the REST API. The room is private if
+// password is not empty.
+func CreateRoom(server, password string) (*game.Room, error) {
+	req, err := http.NewRequest("POST", strings.TrimSuffix(server, "/")+"/api/room", nil)
+	if err != nil {
+		return nil, err
+	}
+	if password != "" {
+		req.Header.Set("X-Password", password)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		Room  *game.Room `json:"room"`
+		Error string     `json:"error"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if res.StatusCode != 200 || body.Room == nil {
+		return nil, fmt.Errorf("%s: %s", res.Status, body.Error)
+	}
+	return body.Room, nil
+}
@@ -0,0 +1,142 @@
+// Command cardgame-cli plays cardgame from a terminal. It connects to a room
+// over the websocket API, draws the table with ANSI escape codes and reads
+// one command per line. It is also handy for debugging the protocol without
+// the web frontend.
+//
+// Usage:
+//
+//	cardgame-cli [-server http://localhost:8080] [-room r_abc] [-password pw] [-name name]
+//
+// Without -room, a new room is created.
+package main
+
+import (
+	"bufio"
+	"cardgame/client"
+	"cardgame/game"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func main() {
+	server := flag.String("server", "http://localhost:8080", "server URL")
+	roomId := flag.String("room", "", "room to join; a new room is created if empty")
+	password := flag.String("password", "", "room password")
+	name := flag.String("name", "", "display name; a random one is used if empty")
+	flag.Parse()
+
+	if err := run(strings.TrimSuffix(*server, "/"), *roomId, *password, *name); err != nil {
+		fmt.Fprintln(os.Stderr, "cardgame-cli:", err)
+		os.Exit(1)
+	}
+}
+
+func run(server, roomId, password, name string) error {
+	if roomId == "" {
+		room, err := client.CreateRoom(server, password)
+		if err != nil {
+			return fmt.Errorf("failed to create room: %w", err)
+		}
+		roomId = room.Id
+	}
+
+	c, err := client.Dial(client.RoomURL(server, roomId), client.Options{Reconnect: true})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	v := &view{table: table{Id: roomId}}
+	if err := c.Join(roomId, password, name); err != nil {
+		return err
+	}
+	v.me = c.PlayerId()
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	for {
+		select {
+		case m, ok := <-c.Messages():
+			if !ok {
+				return fmt.Errorf("connection lost: %w", c.Err())
+			}
+			state, _ := c.State()
+			if err := v.apply(m, state); err != nil {
+				v.status = red + err.Error() + reset
+			}
+			if _, ok := m.(*game.ServerRoomClosed); ok {
+				v.render(os.Stdout)
+				fmt.Println()
+				return nil
+			}
+		case line, ok := <-lines:
+			if !ok {
+				return nil
+			}
+			quit, err := v.command(c, strings.TrimSpace(line))
+			if err != nil {
+				return err
+			}
+			if quit {
+				return nil
+			}
+		}
+		v.render(os.Stdout)
+	}
+}
+
+// command runs a line of input. It returns true if the client should exit.
+func (v *view) command(c *client.Client, line string) (bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false, nil
+	}
+
+	var message game.ClientMessage
+	switch cmd := strings.ToLower(fields[0]); {
+	case cmd == "q" || cmd == "quit":
+		_, err := c.Send(game.ClientLeave{})
+		return true, err
+	case cmd == "d" || cmd == "draw":
+		message = game.ClientDraw{}
+	case cmd == "r" || cmd == "ready":
+		me, _ := v.mySeat()
+		message = game.ClientReady{Ready: !me.Ready}
+	case cmd == "start":
+		message = game.ClientStart{}
+	case cmd == "say":
+		message = game.ClientChat{Message: strings.TrimSpace(strings.TrimPrefix(line, fields[0]))}
+	case cmd == "s" || cmd == "send" || len(fields) == 1 && isNumber(cmd):
+		query := cmd
+		if !isNumber(cmd) {
+			if len(fields) < 2 {
+				v.status = red + "usage: send <player number or name>" + reset
+				return false, nil
+			}
+			query = fields[1]
+		}
+		target, ok := v.seat(query)
+		if !ok {
+			v.status = red + "no player " + query + reset
+			return false, nil
+		}
+		message = game.ClientSend{RecipientId: target.Id}
+	default:
+		message = game.ClientChat{Message: line}
+	}
+	_, err := c.Send(message)
+	return false, err
+}
+
+func isNumber(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
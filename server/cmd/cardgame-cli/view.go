@@ -0,0 +1,252 @@
+package main
+
+import (
+	"cardgame/card"
+	"cardgame/game"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ANSI escape sequences used for rendering.
+const (
+	clearScreen = "\x1b[H\x1b[2J"
+	bold        = "\x1b[1m"
+	dim         = "\x1b[2m"
+	red         = "\x1b[31m"
+	green       = "\x1b[32m"
+	yellow      = "\x1b[33m"
+	cyan        = "\x1b[36m"
+	reset       = "\x1b[0m"
+)
+
+// chatLines is the number of chat messages shown below the table.
+const chatLines = 10
+
+// table is the part of the room state shown by the client.
+type table struct {
+	Id             string         `json:"id"`
+	Name           string         `json:"name"`
+	OwnerId        string         `json:"ownerId"`
+	Players        []seat         `json:"players"`
+	Spectators     []seat         `json:"spectators"`
+	CurrentTurn    int            `json:"currentTurn"`
+	GamePhase      game.GamePhase `json:"gamePhase"`
+	ActiveWildCard *card.WildCard `json:"activeWildCard"`
+	DrawPileSize   int            `json:"drawPileSize"`
+	Paused         bool           `json:"paused"`
+}
+
+// seat is a player as shown by the client.
+type seat struct {
+	Id        string       `json:"id"`
+	Name      string       `json:"name"`
+	Score     int          `json:"score"`
+	Cards     []*card.Card `json:"cards"`
+	Connected bool         `json:"connected"`
+	Ready     bool         `json:"ready"`
+}
+
+func (s seat) top() *card.Card {
+	if len(s.Cards) == 0 {
+		return nil
+	}
+	return s.Cards[len(s.Cards)-1]
+}
+
+// view is the client's picture of the room, built from server messages.
+type view struct {
+	me     string // player id, from ServerSession
+	table  table
+	chat   []string
+	status string // result of the last action, or an error
+}
+
+// apply updates the view with a server message and the room state after it.
+func (v *view) apply(message game.ServerMessage, state any) error {
+	if state != nil {
+		data, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+		v.table = table{}
+		if err := json.Unmarshal(data, &v.table); err != nil {
+			return err
+		}
+	}
+
+	switch m := message.(type) {
+	case *game.ServerSession:
+		v.me = m.PlayerId
+	case *game.ServerChatHistory:
+		for _, c := range m.Messages {
+			v.addChat(c)
+		}
+	case *game.ServerChat:
+		v.addChat(m)
+	case *game.ServerError:
+		v.status = red + "error: " + m.Message + reset
+	case *game.ServerAck:
+		v.status = dim + m.Action + " ok" + reset
+	case *game.ServerDraw:
+		v.status = fmt.Sprintf("%s drew %s", v.name(m.PlayerId), formatCard(m.Card))
+	case *game.ServerWildCard:
+		v.status = fmt.Sprintf("%s drew wild card %s", v.name(m.PlayerId), formatWildCard(m.Card))
+	case *game.ServerSend:
+		v.status = fmt.Sprintf("%s sent %s to %s", v.name(m.SenderId), formatCard(m.Card), v.name(m.RecipientId))
+	case *game.ServerCountdown:
+		if m.Seconds > 0 {
+			v.status = fmt.Sprintf("game starts in %ds", m.Seconds)
+		} else {
+			v.status = "countdown cancelled"
+		}
+	case *game.ServerEnd:
+		v.status = yellow + "game over: " + m.Reason + reset
+	case *game.ServerAnnouncement:
+		v.status = yellow + "announcement: " + m.Message + reset
+	case *game.ServerKick:
+		v.status = red + "you were kicked" + reset
+	case *game.ServerRoomClosed:
+		v.status = red + "room closed: " + m.Reason + reset
+	case *game.ServerShutdown:
+		v.status = red + "server shutting down: " + m.Reason + reset
+	}
+	return nil
+}
+
+func (v *view) addChat(c *game.ServerChat) {
+	line := cyan + v.name(c.PlayerId) + reset + ": " + c.Message
+	if c.System {
+		line = dim + c.Message + reset
+	} else if c.Private {
+		line = dim + "(private) " + reset + line
+	}
+	v.chat = append(v.chat, line)
+	if len(v.chat) > chatLines {
+		v.chat = v.chat[len(v.chat)-chatLines:]
+	}
+}
+
+// name returns the name of the player or spectator with the given id.
+func (v *view) name(id string) string {
+	if id == "" {
+		return "server"
+	}
+	for _, s := range append(append([]seat{}, v.table.Players...), v.table.Spectators...) {
+		if s.Id == id {
+			return s.Name
+		}
+	}
+	return id
+}
+
+// seat returns the player with the given number as shown in the table, or a
+// player whose name starts with query.
+func (v *view) seat(query string) (seat, bool) {
+	if n, err := strconv.Atoi(query); err == nil && n >= 1 && n <= len(v.table.Players) {
+		return v.table.Players[n-1], true
+	}
+	for _, s := range v.table.Players {
+		if strings.HasPrefix(strings.ToLower(s.Name), strings.ToLower(query)) {
+			return s, true
+		}
+	}
+	return seat{}, false
+}
+
+// mySeat returns the seat of the client's player.
+func (v *view) mySeat() (seat, bool) {
+	for _, s := range v.table.Players {
+		if s.Id == v.me {
+			return s, true
+		}
+	}
+	return seat{}, false
+}
+
+func formatCard(c *card.Card) string {
+	if c == nil {
+		return dim + "[ empty ]" + reset
+	}
+	return fmt.Sprintf("[%s%s%s %s]", bold, c.Type.String(), reset, c.Category)
+}
+
+func formatWildCard(w *card.WildCard) string {
+	if w == nil || len(w.Types) < 2 {
+		return dim + "none" + reset
+	}
+	return fmt.Sprintf("[%s%s = %s%s]", bold, w.Types[0].String(), w.Types[1].String(), reset)
+}
+
+var phaseNames = map[game.GamePhase]string{
+	game.GamePhaseLobby:   "lobby",
+	game.GamePhasePlaying: "playing",
+	game.GamePhaseEnd:     "game over",
+}
+
+// render draws the whole screen.
+func (v *view) render(w io.Writer) {
+	t := v.table
+	var b strings.Builder
+	b.WriteString(clearScreen)
+
+	phase := phaseNames[t.GamePhase]
+	if t.Paused {
+		phase += ", paused"
+	}
+	fmt.Fprintf(&b, "%s%s%s  %s(%s, %s)%s\n", bold, t.Name, reset, dim, t.Id, phase, reset)
+	fmt.Fprintf(&b, "wild card %s   draw pile %d\n\n", formatWildCard(t.ActiveWildCard), t.DrawPileSize)
+
+	for i, s := range t.Players {
+		marker := "  "
+		if t.GamePhase == game.GamePhasePlaying && i == t.CurrentTurn {
+			marker = green + "▶ " + reset
+		}
+		name := s.Name
+		if s.Id == v.me {
+			name += " (you)"
+		}
+		if !s.Connected {
+			name += " (away)"
+		}
+		name = fmt.Sprintf("%-24s", name)
+		if s.Id == v.me {
+			name = bold + name + reset
+		} else if !s.Connected {
+			name = dim + name + reset
+		}
+		var flags []string
+		if s.Id == t.OwnerId {
+			flags = append(flags, "owner")
+		}
+		if t.GamePhase != game.GamePhasePlaying && s.Ready {
+			flags = append(flags, "ready")
+		}
+		extra := ""
+		if len(flags) > 0 {
+			extra = dim + " " + strings.Join(flags, ", ") + reset
+		}
+		fmt.Fprintf(&b, "%s%d. %s %s  %d cards, score %d%s\n", marker, i+1, name, formatCard(s.top()), len(s.Cards), s.Score, extra)
+	}
+	if len(t.Spectators) > 0 {
+		names := []string{}
+		for _, s := range t.Spectators {
+			names = append(names, s.Name)
+		}
+		fmt.Fprintf(&b, "%sspectating: %s%s\n", dim, strings.Join(names, ", "), reset)
+	}
+
+	b.WriteString("\n")
+	for _, line := range v.chat {
+		b.WriteString(line + "\n")
+	}
+	for i := len(v.chat); i < chatLines; i++ {
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "\n%s\n", v.status)
+	fmt.Fprintf(&b, "%sd draw · <n> send to player n · r ready · start · /help · q quit · anything else chats%s\n> ", dim, reset)
+	io.WriteString(w, b.String())
+}
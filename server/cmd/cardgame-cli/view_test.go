@@ -0,0 +1,46 @@
+package main
+
+import (
+	"cardgame/card"
+	"cardgame/game"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViewApply(t *testing.T) {
+	v := &view{}
+	state := map[string]any{
+		"id":          "r_1",
+		"name":        "Test Room",
+		"gamePhase":   1.0,
+		"currentTurn": 0.0,
+		"players": []any{
+			map[string]any{"id": "p_1", "name": "Alice", "connected": true, "cards": []any{}},
+			map[string]any{"id": "p_2", "name": "Bob", "connected": true, "cards": []any{}},
+		},
+	}
+	assert.NoError(t, v.apply(&game.ServerSession{PlayerId: "p_1"}, state))
+	assert.Len(t, v.table.Players, 2)
+
+	patch := game.Patch{{Op: "add", Path: "/players/1/cards/0", Value: map[string]any{"type": float64(card.Star), "category": "Fruit"}}}
+	next, err := game.ApplyPatch(state, patch)
+	assert.NoError(t, err)
+	assert.NoError(t, v.apply(&game.ServerDraw{PlayerId: "p_2"}, next))
+	assert.Equal(t, card.Star, v.table.Players[1].top().Type)
+	assert.Contains(t, v.status, "Bob drew")
+
+	target, ok := v.seat("2")
+	assert.True(t, ok)
+	assert.Equal(t, "p_2", target.Id)
+	target, ok = v.seat("ali")
+	assert.True(t, ok)
+	assert.Equal(t, "p_1", target.Id)
+
+	var out strings.Builder
+	v.render(&out)
+	assert.Contains(t, out.String(), "Test Room")
+	assert.Contains(t, out.String(), card.Star.String()+reset+" Fruit")
+	assert.Contains(t, out.String(), "Alice (you)")
+}
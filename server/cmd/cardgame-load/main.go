@@ -0,0 +1,162 @@
+// Command cardgame-load simulates rooms full of scripted players against a
+// running server, to find out how many rooms it can hold. Every room plays
+// games over real websocket connections, one after another, until the
+// duration is over.
+//
+// While running, it periodically prints the request latency percentiles, the
+// number of failed requests and the server's resource usage from /metrics.
+// At the end, it prints latency percentiles by message type and the errors
+// by code.
+//
+// Usage:
+//
+//	cardgame-load [-server http://localhost:8080] [-rooms 10] [-players 4] [-duration 1m]
+//	              [-think 500ms] [-turns 40] [-chat 0.05] [-report 5s]
+//
+// The server must have at least one deck, and its room and rate limits apply
+// to the simulated players like to anyone else.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+func main() {
+	server := flag.String("server", "http://localhost:8080", "server URL")
+	rooms := flag.Int("rooms", 10, "number of rooms to simulate at the same time")
+	players := flag.Int("players", 4, "players per room")
+	duration := flag.Duration("duration", time.Minute, "how long to run")
+	think := flag.Duration("think", 500*time.Millisecond, "average time between the actions of a player")
+	turns := flag.Int("turns", 40, "cards drawn per game")
+	chat := flag.Float64("chat", 0.05, "probability that a waiting player chats")
+	report := flag.Duration("report", 5*time.Second, "interval between progress reports")
+	flag.Parse()
+
+	s := &settings{
+		server:  strings.TrimSuffix(*server, "/"),
+		players: *players,
+		turns:   *turns,
+		think:   *think,
+		chat:    *chat,
+	}
+	if err := run(s, *rooms, *duration, *report); err != nil {
+		fmt.Fprintln(os.Stderr, "cardgame-load:", err)
+		os.Exit(1)
+	}
+}
+
+func run(s *settings, rooms int, duration, report time.Duration) error {
+	if rooms < 1 || s.players < 2 || s.turns < 1 || s.think <= 0 || report <= 0 {
+		return fmt.Errorf("need at least 1 room, 2 players and 1 turn, and positive intervals")
+	}
+
+	decks, err := fetchDecks(s.server)
+	if err != nil {
+		return fmt.Errorf("failed to get decks: %w", err)
+	}
+	if len(decks) == 0 {
+		return fmt.Errorf("the server has no decks")
+	}
+	s.decks = decks
+
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	st := newStats()
+	fmt.Printf("simulating %d rooms with %d players for %s against %s\n", rooms, s.players, duration, s.server)
+
+	var wg sync.WaitGroup
+	for i := 0; i < rooms; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if err := playGame(ctx, s, st); err != nil && ctx.Err() == nil {
+					// back off a little so a failing server is not flooded
+					time.Sleep(time.Second)
+				}
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	start := time.Now()
+	var peak serverStats
+	ticker := time.NewTicker(report)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			server, err := scrapeMetrics(s.server)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "failed to read metrics:", err)
+			} else {
+				peak.max(server)
+			}
+			printProgress(time.Since(start), st, server)
+		case <-done:
+			fmt.Printf("\nfinished after %s\n", time.Since(start).Round(time.Second))
+			st.writeSummary(os.Stdout)
+			fmt.Printf("\npeak server usage: %.0f websockets, %.0f rooms, %.0f goroutines, %s heap, %s sys\n",
+				peak.Websockets, peak.Rooms, peak.Goroutines, megabytes(peak.HeapInuse), megabytes(peak.Sys))
+			return nil
+		}
+	}
+}
+
+// printProgress prints a line with the latencies since the last report and
+// the server's current resource usage, if known.
+func printProgress(elapsed time.Duration, st *stats, server *serverStats) {
+	window := st.takeWindow()
+	rooms, games := st.counts()
+
+	line := fmt.Sprintf("%6s rooms=%d games=%d requests=%d p50=%s p99=%s errors=%d",
+		elapsed.Round(time.Second), rooms, games, len(window),
+		round(percentile(window, 50)), round(percentile(window, 99)), st.errorCount())
+	if server != nil {
+		line += fmt.Sprintf(" | server websockets=%.0f rooms=%.0f goroutines=%.0f heap=%s sys=%s gc=%.0f",
+			server.Websockets, server.Rooms, server.Goroutines, megabytes(server.HeapInuse), megabytes(server.Sys), server.GCCycles)
+	}
+	fmt.Println(line)
+}
+
+// fetchDecks returns the ids of the decks available on the server.
+func fetchDecks(server string) ([]string, error) {
+	res, err := http.Get(server + "/api/decks")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("GET /api/decks: %s", res.Status)
+	}
+
+	var body struct {
+		Decks map[string]json.RawMessage `json:"decks"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(body.Decks))
+	for id := range body.Decks {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// serverStats are the resource usage figures read from the server's
+// /metrics endpoint.
+type serverStats struct {
+	Websockets float64
+	Rooms      float64
+	Goroutines float64
+	HeapInuse  float64 // bytes
+	Sys        float64 // bytes obtained from the OS
+	GCCycles   float64
+}
+
+// scrapeMetrics reads the server's metrics.
+func scrapeMetrics(server string) (*serverStats, error) {
+	res, err := http.Get(server + "/metrics")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("GET /metrics: %s", res.Status)
+	}
+
+	samples, err := parseMetrics(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	s := &serverStats{
+		Websockets: samples["cardgame_websockets"],
+		Goroutines: samples["go_goroutines"],
+		HeapInuse:  samples[`go_memstats_bytes{kind="heap_inuse"}`],
+		Sys:        samples[`go_memstats_bytes{kind="sys"}`],
+		GCCycles:   samples["go_gc_cycles"],
+	}
+	for series, value := range samples {
+		if strings.HasPrefix(series, "cardgame_rooms{") {
+			s.Rooms += value
+		}
+	}
+	return s, nil
+}
+
+// parseMetrics parses the Prometheus text format into values by series,
+// e.g. `go_memstats_bytes{kind="sys"}`.
+func parseMetrics(r io.Reader) (map[string]float64, error) {
+	samples := map[string]float64{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			return nil, fmt.Errorf("invalid metrics line %q", line)
+		}
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metrics line %q: %w", line, err)
+		}
+		samples[line[:i]] = value
+	}
+	return samples, scanner.Err()
+}
+
+// megabytes formats a number of bytes.
+func megabytes(bytes float64) string {
+	return strconv.FormatFloat(bytes/(1<<20), 'f', 1, 64) + "MB"
+}
+
+// max raises every figure of s to the one in other, if higher.
+func (s *serverStats) max(other *serverStats) {
+	fields := []struct{ s, other *float64 }{
+		{&s.Websockets, &other.Websockets},
+		{&s.Rooms, &other.Rooms},
+		{&s.Goroutines, &other.Goroutines},
+		{&s.HeapInuse, &other.HeapInuse},
+		{&s.Sys, &other.Sys},
+		{&s.GCCycles, &other.GCCycles},
+	}
+	for _, f := range fields {
+		if *f.other > *f.s {
+			*f.s = *f.other
+		}
+	}
+}
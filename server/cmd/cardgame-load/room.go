@@ -0,0 +1,175 @@
+package main
+
+import (
+	"cardgame/client"
+	"cardgame/game"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// settings are the parameters of the simulation.
+type settings struct {
+	server  string
+	players int           // players per room
+	turns   int           // cards drawn per game
+	think   time.Duration // average time between the actions of a player
+	chat    float64       // probability of chatting instead of waiting for a turn
+	decks   []string      // deck ids to play with
+}
+
+// bot is a scripted player.
+type bot struct {
+	c     *client.Client
+	stats *stats
+}
+
+// do sends a message, waits for the response and records its latency.
+func (b *bot) do(message game.ClientMessage) error {
+	start := time.Now()
+	err := b.c.Do(message)
+	b.stats.request(message.ClientType(), time.Since(start), err)
+	return err
+}
+
+// playGame creates a room, fills it with players and plays a game until
+// the configured number of cards were drawn. Everyone leaves afterwards,
+// which ends the game and closes the room. If ctx is done first, its error
+// is returned.
+func playGame(ctx context.Context, s *settings, st *stats) (err error) {
+	st.roomStarted()
+	defer func() { st.roomEnded(err) }()
+
+	room, err := client.CreateRoom(s.server, "")
+	if err != nil {
+		st.request("create_room", 0, err)
+		return err
+	}
+
+	// bots that joined the room
+	bots := make([]*bot, 0, s.players)
+	defer func() {
+		for _, b := range bots {
+			b.do(game.ClientLeave{})
+			b.c.Close()
+		}
+	}()
+	for i := 0; i < s.players; i++ {
+		c, err := client.Dial(client.RoomURL(s.server, room.Id), client.Options{
+			Handler: func(game.ServerMessage) { st.messageReceived() },
+		})
+		if err != nil {
+			st.request("hello", 0, err)
+			return err
+		}
+		b := &bot{c: c, stats: st}
+		if err := b.do(game.ClientJoin{RoomId: room.Id, Name: fmt.Sprintf("bot %d", i+1)}); err != nil {
+			c.Close()
+			return err
+		}
+		bots = append(bots, b)
+	}
+
+	owner := bots[0]
+	if err := owner.do(game.ClientChangeDetails{MaxPlayers: &s.players, AddDecks: s.decks}); err != nil {
+		return err
+	}
+	for _, b := range bots[1:] {
+		if err := b.do(game.ClientReady{Ready: true}); err != nil {
+			return err
+		}
+	}
+	if err := owner.do(game.ClientStart{}); err != nil {
+		return err
+	}
+
+	var drawn int64
+	var wg sync.WaitGroup
+	for _, b := range bots {
+		wg.Add(1)
+		go func(b *bot) {
+			defer wg.Done()
+			b.play(ctx, s, &drawn)
+		}(b)
+	}
+	wg.Wait()
+
+	if atomic.LoadInt64(&drawn) < int64(s.turns) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return errors.New("game ended early")
+	}
+	return nil
+}
+
+// play makes moves until ctx is done, the game is over or drawn reaches the
+// number of turns. On their turn, a player draws a card. Otherwise, they send
+// their top card to a player with a compatible one, or sometimes chat.
+func (b *bot) play(ctx context.Context, s *settings, drawn *int64) {
+	for atomic.LoadInt64(drawn) < int64(s.turns) {
+		// think for 50% to 150% of the configured time
+		think := s.think/2 + time.Duration(rand.Int63n(int64(s.think)+1))
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.c.Done():
+			return
+		case <-time.After(think):
+		}
+
+		room, err := b.c.Room()
+		if err != nil || room == nil || room.GamePhase != game.GamePhasePlaying {
+			return
+		}
+		if room.Paused {
+			continue
+		}
+
+		me := b.c.PlayerId()
+		var mine *game.Player
+		for _, p := range room.Players {
+			if p.Id == me {
+				mine = p
+			}
+		}
+		if mine == nil {
+			return
+		}
+
+		if room.Players[room.CurrentTurn].Id == me {
+			if b.do(game.ClientDraw{}) == nil {
+				atomic.AddInt64(drawn, 1)
+			}
+			continue
+		}
+
+		if target := compatiblePlayer(room, mine); target != "" {
+			b.do(game.ClientSend{RecipientId: target})
+		} else if rand.Float64() < s.chat {
+			b.do(game.ClientChat{Message: "good game"})
+		}
+	}
+}
+
+// compatiblePlayer returns the id of a player whose top card matches the top
+// card of p, or "" if there is none.
+func compatiblePlayer(room *game.Room, p *game.Player) string {
+	if len(p.Hand) == 0 {
+		return ""
+	}
+	top := p.Hand[len(p.Hand)-1]
+	for _, other := range room.Players {
+		if other.Id == p.Id || len(other.Hand) == 0 {
+			continue
+		}
+		if top.CompatibleWith(other.Hand[len(other.Hand)-1], room.ActiveWildCard) {
+			return other.Id
+		}
+	}
+	return ""
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"cardgame/client"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// stats collects the results of all simulated players.
+type stats struct {
+	received int64 // server messages received, updated atomically
+
+	mu       sync.Mutex
+	all      map[string][]time.Duration // request latencies by message type
+	window   []time.Duration            // request latencies since the last report
+	errors   map[string]int             // failed requests by error code
+	rooms    int                        // rooms currently simulated
+	games    int                        // games played to the end
+	failures int                        // games aborted because of an error
+}
+
+func newStats() *stats {
+	return &stats{
+		all:    map[string][]time.Duration{},
+		errors: map[string]int{},
+	}
+}
+
+// request records the result of a request of the given message type.
+// Requests rejected by the server count as errors and not towards latency.
+func (s *stats) request(messageType string, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.errors[errorCode(err)]++
+		return
+	}
+	s.all[messageType] = append(s.all[messageType], latency)
+	s.window = append(s.window, latency)
+}
+
+// errorCode returns the server's error code for err, or a short description
+// for errors on the client side.
+func errorCode(err error) string {
+	var e *client.Error
+	switch {
+	case errors.As(err, &e):
+		return string(e.Code)
+	case errors.Is(err, client.ErrClosed):
+		return "connection_closed"
+	case strings.Contains(err.Error(), "timed out"):
+		return "timeout"
+	}
+	return "connection_error"
+}
+
+// messageReceived counts a message received by a player.
+func (s *stats) messageReceived() {
+	atomic.AddInt64(&s.received, 1)
+}
+
+// roomStarted counts a simulated room.
+func (s *stats) roomStarted() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rooms++
+}
+
+// roomEnded counts a game as finished or failed, unless it was interrupted
+// because the simulation is over.
+func (s *stats) roomEnded(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rooms--
+	switch {
+	case err == nil:
+		s.games++
+	case !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded):
+		s.failures++
+	}
+}
+
+// counts returns the number of rooms currently simulated and the number of
+// games played to the end.
+func (s *stats) counts() (rooms, games int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rooms, s.games
+}
+
+// takeWindow returns the latencies recorded since the last call, sorted.
+func (s *stats) takeWindow() []time.Duration {
+	s.mu.Lock()
+	window := s.window
+	s.window = nil
+	s.mu.Unlock()
+	sort.Slice(window, func(i, j int) bool { return window[i] < window[j] })
+	return window
+}
+
+// percentile returns the p-th percentile (0-100) of sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p / 100 * float64(len(sorted)))
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// writeSummary writes latency percentiles by message type and error counts.
+func (s *stats) writeSummary(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintf(w, "games: %d finished, %d failed; messages received: %d\n\n", s.games, s.failures, atomic.LoadInt64(&s.received))
+
+	types := make([]string, 0, len(s.all))
+	for t := range s.all {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	fmt.Fprintf(w, "%-20s %8s %10s %10s %10s %10s\n", "request", "count", "p50", "p90", "p99", "max")
+	for _, t := range types {
+		latencies := append([]time.Duration{}, s.all[t]...)
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		fmt.Fprintf(w, "%-20s %8d %10s %10s %10s %10s\n", t, len(latencies),
+			round(percentile(latencies, 50)),
+			round(percentile(latencies, 90)),
+			round(percentile(latencies, 99)),
+			round(latencies[len(latencies)-1]))
+	}
+
+	if len(s.errors) > 0 {
+		codes := make([]string, 0, len(s.errors))
+		for code := range s.errors {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		fmt.Fprintf(w, "\n%-20s %8s\n", "error", "count")
+		for _, code := range codes {
+			fmt.Fprintf(w, "%-20s %8d\n", code, s.errors[code])
+		}
+	}
+}
+
+// errorCount returns the total number of failed requests.
+func (s *stats) errorCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, count := range s.errors {
+		n += count
+	}
+	return n
+}
+
+// round rounds a latency for display.
+func round(d time.Duration) time.Duration {
+	switch {
+	case d > time.Second:
+		return d.Round(time.Millisecond)
+	case d > time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	}
+	return d.Round(time.Microsecond)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"cardgame/client"
+	"cardgame/game"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	st := newStats()
+	for i := 1; i <= 100; i++ {
+		st.request("draw", time.Duration(i)*time.Millisecond, nil)
+	}
+	st.request("draw", 0, &client.Error{ServerError: game.ServerError{Code: game.ErrorNotYourTurn}})
+	st.request("chat", 0, client.ErrClosed)
+
+	window := st.takeWindow()
+	assert.Len(t, window, 100)
+	assert.Equal(t, 51*time.Millisecond, percentile(window, 50))
+	assert.Equal(t, 100*time.Millisecond, percentile(window, 99.9))
+	assert.Empty(t, st.takeWindow(), "the window is reset")
+	assert.Equal(t, 2, st.errorCount())
+
+	st.roomStarted()
+	st.roomEnded(nil)
+	st.roomStarted()
+	st.roomEnded(context.Canceled)
+	st.roomStarted()
+	st.roomEnded(errors.New("game ended early"))
+	rooms, games := st.counts()
+	assert.Equal(t, 0, rooms)
+	assert.Equal(t, 1, games)
+
+	var out strings.Builder
+	st.writeSummary(&out)
+	assert.Contains(t, out.String(), "games: 1 finished, 1 failed")
+	assert.Regexp(t, `draw\s+100\s+51ms`, out.String())
+	assert.Regexp(t, `not_your_turn\s+1`, out.String())
+	assert.Regexp(t, `connection_closed\s+1`, out.String())
+}
+
+func TestParseMetrics(t *testing.T) {
+	samples, err := parseMetrics(strings.NewReader(`# HELP go_goroutines Number of goroutines that currently exist.
+# TYPE go_goroutines gauge
+go_goroutines 12
+go_memstats_bytes{kind="heap_inuse"} 4.5e+06
+cardgame_rooms{phase="lobby"} 2
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float64{
+		"go_goroutines":                        12,
+		`go_memstats_bytes{kind="heap_inuse"}`: 4.5e6,
+		`cardgame_rooms{phase="lobby"}`:        2,
+	}, samples)
+
+	_, err = parseMetrics(strings.NewReader("go_goroutines twelve\n"))
+	assert.Error(t, err)
+}
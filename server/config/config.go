@@ -0,0 +1,431 @@
+// Package config loads the server configuration from a YAML file, the
+// environment and command line flags, in increasing order of precedence.
+package config
+
+import (
+	"cardgame/build"
+	"cardgame/util/logging"
+	"cardgame/util/origin"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of the server.
+type Config struct {
+	Addr             string   `yaml:"addr"`             // address to listen on
+	DeckDirs         []string `yaml:"deckDirs"`         // directories to load decks from
+	AllowedOrigins   []string `yaml:"allowedOrigins"`   // origins allowed to use the API besides the server's own, "*" for all
+	AdminToken       string   `yaml:"adminToken"`       // bearer token for the admin API, empty to disable it
+	ChatFilter       []string `yaml:"chatFilter"`       // words replaced in chat messages and names
+	ShutdownSnapshot string   `yaml:"shutdownSnapshot"` // file to write the state of all rooms to on shutdown
+
+	Rooms      RoomConfig      `yaml:"rooms"`
+	RateLimits RateLimitConfig `yaml:"rateLimits"`
+	Queue      QueueConfig     `yaml:"queue"`
+	Timeouts   TimeoutConfig   `yaml:"timeouts"`
+	Log        LogConfig       `yaml:"log"`
+}
+
+// RoomConfig limits rooms and their players.
+type RoomConfig struct {
+	MaxRooms             int `yaml:"maxRooms"`             // maximum number of rooms, 0 for no limit
+	MaxPlayers           int `yaml:"maxPlayers"`           // default maximum number of players in a new room
+	MaxPlayersLimit      int `yaml:"maxPlayersLimit"`      // highest maximum number of players an owner can choose
+	MaxSpectators        int `yaml:"maxSpectators"`        // maximum number of spectators in a room
+	MaxNameLength        int `yaml:"maxNameLength"`        // maximum length of room and player names, in characters
+	MaxDescriptionLength int `yaml:"maxDescriptionLength"` // maximum length of room descriptions, in characters
+
+	// BanByAddress also bans the network address of banned players, so they
+	// cannot rejoin from a new connection. This bans everyone behind the same
+	// NAT or proxy. Otherwise bans only apply to the banned player's session,
+	// which a new connection does not share.
+	BanByAddress bool `yaml:"banByAddress"`
+}
+
+// RateLimit allows Rate messages per second on average, with bursts of up to
+// Burst messages.
+type RateLimit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst float64 `yaml:"burst"`
+}
+
+// RateLimitConfig limits the messages of each connection. Rejected messages
+// count as violations, and the connection is closed once they exceed
+// Violations.
+type RateLimitConfig struct {
+	PerType    map[string]RateLimit `yaml:"perType"`    // limits by client message type
+	Default    RateLimit            `yaml:"default"`    // limit for types not in PerType
+	Total      RateLimit            `yaml:"total"`      // limit for all messages together
+	Violations RateLimit            `yaml:"violations"` // rejected messages tolerated
+}
+
+// Overflow policies for QueueConfig.Overflow.
+const (
+	// OverflowDrop drops non-critical messages (chat, resyncs, presence
+	// updates) and disconnects the client if a critical message does not fit.
+	OverflowDrop = "drop"
+	// OverflowDisconnect disconnects the client as soon as the queue is full.
+	OverflowDisconnect = "disconnect"
+)
+
+// QueueConfig configures the queue of messages waiting to be sent to each
+// player, so that one slow connection cannot block a room.
+type QueueConfig struct {
+	Size     int    `yaml:"size"`     // messages queued before the overflow policy applies
+	MaxSize  int    `yaml:"maxSize"`  // messages queued before the queue is discarded and the client has to resync
+	Overflow string `yaml:"overflow"` // OverflowDrop or OverflowDisconnect
+	Coalesce bool   `yaml:"coalesce"` // replace queued resyncs with newer ones
+}
+
+// TimeoutConfig holds connection and shutdown timeouts.
+type TimeoutConfig struct {
+	Write     time.Duration `yaml:"write"`     // time allowed to write a message to a client
+	Pong      time.Duration `yaml:"pong"`      // time allowed to read the next pong from a client
+	Reconnect time.Duration `yaml:"reconnect"` // time a disconnected player keeps their seat
+	Shutdown  time.Duration `yaml:"shutdown"`  // time allowed to close all connections on shutdown
+}
+
+// LogConfig configures the logger.
+type LogConfig struct {
+	Level  string `yaml:"level"`  // debug, info, warn or error
+	Format string `yaml:"format"` // logfmt or json
+}
+
+// PingPeriod is how often clients are pinged. It is shorter than the pong
+// timeout so that a pong can arrive in time.
+func (t TimeoutConfig) PingPeriod() time.Duration {
+	return t.Pong * 9 / 10
+}
+
+// MinPlayers is the number of players needed to start a game, and so the
+// lowest maximum number of players of a room.
+const MinPlayers = 2
+
+const (
+	maxPlayersCap    = 64
+	maxSpectatorsCap = 64
+	maxLengthCap     = 10000
+)
+
+// DevOrigins are the allowed origins in development mode: any port on
+// localhost.
+var DevOrigins = []string{"http://localhost:*", "http://127.0.0.1:*", "http://[::1]:*"}
+
+// Default returns the default configuration. In development mode, pages
+// served from localhost may use the API.
+func Default() *Config {
+	var origins []string
+	if build.Mode() != "release" {
+		origins = append(origins, DevOrigins...)
+	}
+
+	return &Config{
+		Addr:           ":8080",
+		DeckDirs:       []string{"./data/decks"},
+		AllowedOrigins: origins,
+		Rooms: RoomConfig{
+			MaxPlayers:           4,
+			MaxPlayersLimit:      16,
+			MaxSpectators:        16,
+			MaxNameLength:        64,
+			MaxDescriptionLength: 500,
+			BanByAddress:         true,
+		},
+		RateLimits: RateLimitConfig{
+			PerType: map[string]RateLimit{
+				"chat":             {1, 5},
+				"change_details":   {2, 5},
+				"kick":             {1, 3},
+				"request_snapshot": {0.2, 2},
+				"hello":            {0.1, 1},
+				"reconnect":        {0.2, 2},
+			},
+			Default:    RateLimit{10, 20},
+			Total:      RateLimit{20, 40},
+			Violations: RateLimit{10.0 / 60, 10},
+		},
+		Queue: QueueConfig{
+			Size:     256,
+			MaxSize:  1024,
+			Overflow: OverflowDrop,
+			Coalesce: true,
+		},
+		Timeouts: TimeoutConfig{
+			Write:     10 * time.Second,
+			Pong:      60 * time.Second,
+			Reconnect: 30 * time.Second,
+			Shutdown:  10 * time.Second,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "logfmt",
+		},
+	}
+}
+
+// Load returns the default configuration overridden by the config file, the
+// environment and the flags in args, in that order. The config file is given
+// by the -config flag or the CONFIG_FILE environment variable. getenv is
+// usually os.Getenv.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	c := Default()
+
+	fs := flag.NewFlagSet("cardgame", flag.ContinueOnError)
+	file := fs.String("config", getenv("CONFIG_FILE"), "path to a YAML config file")
+	addr := fs.String("addr", "", "address to listen on")
+	decks := fs.String("decks", "", "comma-separated directories to load decks from")
+	maxPlayers := fs.Int("max-players", 0, "default maximum number of players in a room")
+	logLevel := fs.String("log-level", "", "log level: debug, info, warn or error")
+	logFormat := fs.String("log-format", "", "log format: logfmt or json")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *file != "" {
+		if err := c.loadFile(*file); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.loadEnv(getenv); err != nil {
+		return nil, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			c.Addr = *addr
+		case "decks":
+			c.DeckDirs = splitList(*decks)
+		case "max-players":
+			c.Rooms.MaxPlayers = *maxPlayers
+		case "log-level":
+			c.Log.Level = *logLevel
+		case "log-format":
+			c.Log.Format = *logFormat
+		}
+	})
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) loadEnv(getenv func(string) string) error {
+	if port := getenv("PORT"); port != "" {
+		c.Addr = ":" + port
+	}
+	if addr := getenv("ADDR"); addr != "" {
+		c.Addr = addr
+	}
+	if dirs := getenv("DECK_DIRS"); dirs != "" {
+		c.DeckDirs = splitList(dirs)
+	}
+	if origins := getenv("ALLOWED_ORIGINS"); origins != "" {
+		c.AllowedOrigins = splitList(origins)
+	}
+	if token := getenv("ADMIN_TOKEN"); token != "" {
+		c.AdminToken = token
+	}
+	if words := getenv("CHAT_FILTER"); words != "" {
+		c.ChatFilter = splitList(words)
+	}
+	if path := getenv("SHUTDOWN_SNAPSHOT"); path != "" {
+		c.ShutdownSnapshot = path
+	}
+	if level := getenv("LOG_LEVEL"); level != "" {
+		c.Log.Level = level
+	}
+	if format := getenv("LOG_FORMAT"); format != "" {
+		c.Log.Format = format
+	}
+	if overflow := getenv("QUEUE_OVERFLOW"); overflow != "" {
+		c.Queue.Overflow = overflow
+	}
+	if s := getenv("BAN_BY_ADDRESS"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid BAN_BY_ADDRESS: %w", err)
+		}
+		c.Rooms.BanByAddress = b
+	}
+
+	ints := []struct {
+		name  string
+		value *int
+	}{
+		{"MAX_ROOMS", &c.Rooms.MaxRooms},
+		{"MAX_PLAYERS", &c.Rooms.MaxPlayers},
+		{"MAX_PLAYERS_LIMIT", &c.Rooms.MaxPlayersLimit},
+		{"MAX_SPECTATORS", &c.Rooms.MaxSpectators},
+		{"MAX_NAME_LENGTH", &c.Rooms.MaxNameLength},
+		{"MAX_DESCRIPTION_LENGTH", &c.Rooms.MaxDescriptionLength},
+		{"QUEUE_SIZE", &c.Queue.Size},
+		{"QUEUE_MAX_SIZE", &c.Queue.MaxSize},
+	}
+	for _, v := range ints {
+		if s := getenv(v.name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", v.name, err)
+			}
+			*v.value = n
+		}
+	}
+
+	durations := []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"WRITE_TIMEOUT", &c.Timeouts.Write},
+		{"PONG_TIMEOUT", &c.Timeouts.Pong},
+		{"RECONNECT_WINDOW", &c.Timeouts.Reconnect},
+		{"SHUTDOWN_TIMEOUT", &c.Timeouts.Shutdown},
+	}
+	for _, v := range durations {
+		if s := getenv(v.name); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", v.name, err)
+			}
+			*v.value = d
+		}
+	}
+	return nil
+}
+
+// Validate returns an error describing every invalid setting.
+func (c *Config) Validate() error {
+	var problems []string
+	invalid := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Addr == "" {
+		invalid("addr must not be empty")
+	}
+	if len(c.DeckDirs) == 0 {
+		invalid("deckDirs must not be empty")
+	}
+	for _, dir := range c.DeckDirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			invalid("deck directory %q does not exist", dir)
+		}
+	}
+	if _, err := origin.NewAllowList(c.AllowedOrigins); err != nil {
+		invalid("allowedOrigins: %v", err)
+	}
+
+	if c.Rooms.MaxRooms < 0 {
+		invalid("rooms.maxRooms must not be negative")
+	}
+	if c.Rooms.MaxPlayersLimit < MinPlayers || c.Rooms.MaxPlayersLimit > maxPlayersCap {
+		invalid("rooms.maxPlayersLimit must be between %d and %d", MinPlayers, maxPlayersCap)
+	}
+	if c.Rooms.MaxPlayers < MinPlayers || c.Rooms.MaxPlayers > c.Rooms.MaxPlayersLimit {
+		invalid("rooms.maxPlayers must be between %d and rooms.maxPlayersLimit", MinPlayers)
+	}
+	if c.Rooms.MaxSpectators < 0 || c.Rooms.MaxSpectators > maxSpectatorsCap {
+		invalid("rooms.maxSpectators must be between 0 and %d", maxSpectatorsCap)
+	}
+	if c.Rooms.MaxNameLength < 1 || c.Rooms.MaxNameLength > maxLengthCap {
+		invalid("rooms.maxNameLength must be between 1 and %d", maxLengthCap)
+	}
+	if c.Rooms.MaxDescriptionLength < 0 || c.Rooms.MaxDescriptionLength > maxLengthCap {
+		invalid("rooms.maxDescriptionLength must be between 0 and %d", maxLengthCap)
+	}
+
+	rateLimits := map[string]RateLimit{
+		"default":    c.RateLimits.Default,
+		"total":      c.RateLimits.Total,
+		"violations": c.RateLimits.Violations,
+	}
+	for messageType, limit := range c.RateLimits.PerType {
+		rateLimits["perType."+messageType] = limit
+	}
+	names := make([]string, 0, len(rateLimits))
+	for name := range rateLimits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if limit := rateLimits[name]; limit.Rate <= 0 || limit.Burst < 1 {
+			invalid("rateLimits.%s must have a positive rate and a burst of at least 1", name)
+		}
+	}
+
+	if c.Queue.Size < 1 {
+		invalid("queue.size must be positive")
+	}
+	if c.Queue.MaxSize < c.Queue.Size {
+		invalid("queue.maxSize must be at least queue.size")
+	}
+	if c.Queue.Overflow != OverflowDrop && c.Queue.Overflow != OverflowDisconnect {
+		invalid("queue.overflow must be %q or %q", OverflowDrop, OverflowDisconnect)
+	}
+
+	timeouts := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"write", c.Timeouts.Write},
+		{"pong", c.Timeouts.Pong},
+		{"reconnect", c.Timeouts.Reconnect},
+		{"shutdown", c.Timeouts.Shutdown},
+	}
+	for _, t := range timeouts {
+		if t.value <= 0 {
+			invalid("timeouts.%s must be positive", t.name)
+		}
+	}
+
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		invalid("log.level: %v", err)
+	}
+	if _, err := logging.ParseFormat(c.Log.Format); err != nil {
+		invalid("log.format: %v", err)
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid config: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Logger returns a logger writing to stderr with the configured level and
+// format. The configuration must be valid.
+func (c *Config) Logger() *logging.Logger {
+	level, _ := logging.ParseLevel(c.Log.Level)
+	format, _ := logging.ParseFormat(c.Log.Format)
+	return logging.New(os.Stderr, level, format)
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(s string) []string {
+	items := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
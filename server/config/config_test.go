@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	os.WriteFile(file, []byte(`
+addr: ":9000"
+deckDirs: [`+dir+`]
+rooms:
+  maxPlayers: 6
+  maxRooms: 10
+rateLimits:
+  perType:
+    chat: {rate: 2, burst: 10}
+timeouts:
+  reconnect: 1m
+log:
+  level: debug
+`), 0o644)
+
+	c, err := Load([]string{"-log-level", "warn"}, env(map[string]string{
+		"CONFIG_FILE":     file,
+		"MAX_PLAYERS":     "8",
+		"ALLOWED_ORIGINS": "https://example.com, *.example.com",
+		"BAN_BY_ADDRESS":  "false",
+		"MAX_NAME_LENGTH": "32",
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, ":9000", c.Addr, "file overrides defaults")
+	assert.Equal(t, []string{dir}, c.DeckDirs)
+	assert.Equal(t, 10, c.Rooms.MaxRooms)
+	assert.Equal(t, time.Minute, c.Timeouts.Reconnect)
+	assert.Equal(t, 10*time.Second, c.Timeouts.Write, "defaults are kept")
+	assert.Equal(t, 8, c.Rooms.MaxPlayers, "env overrides file")
+	assert.Equal(t, []string{"https://example.com", "*.example.com"}, c.AllowedOrigins)
+	assert.False(t, c.Rooms.BanByAddress)
+	assert.Equal(t, 32, c.Rooms.MaxNameLength)
+	assert.Equal(t, RateLimit{2, 10}, c.RateLimits.PerType["chat"])
+	assert.Equal(t, RateLimit{1, 3}, c.RateLimits.PerType["kick"], "file limits are merged with the defaults")
+	assert.Equal(t, "warn", c.Log.Level, "flags override env and file")
+}
+
+func TestValidate(t *testing.T) {
+	c := Default()
+	c.DeckDirs = []string{t.TempDir()}
+	assert.NoError(t, c.Validate())
+
+	c.Rooms.MaxPlayers = 1
+	c.Timeouts.Pong = 0
+	c.Log.Format = "xml"
+	c.Queue.Overflow = "block"
+	c.Rooms.MaxNameLength = 0
+	c.RateLimits.PerType["chat"] = RateLimit{0, 5}
+	err := c.Validate()
+	assert.ErrorContains(t, err, "rooms.maxPlayers")
+	assert.ErrorContains(t, err, "timeouts.pong")
+	assert.ErrorContains(t, err, "log.format")
+	assert.ErrorContains(t, err, "queue.overflow")
+	assert.ErrorContains(t, err, "rooms.maxNameLength")
+	assert.ErrorContains(t, err, "rateLimits.perType.chat")
+
+	_, err = Load([]string{"-decks", filepath.Join(t.TempDir(), "missing")}, env(nil))
+	assert.ErrorContains(t, err, "does not exist")
+
+	_, err = Load(nil, env(map[string]string{"WRITE_TIMEOUT": "soon"}))
+	assert.ErrorContains(t, err, "WRITE_TIMEOUT")
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 
 	"gopkg.in/yaml.v3"
 )
@@ -33,9 +34,13 @@ var ignoredDirs = []string{
 	".git",
 }
 
-var decks = make(map[string]*Deck)
+var (
+	decks   = make(map[string]*Deck)
+	dirs    []string     // directories passed to InitDecks, for ReloadDecks
+	decksMu sync.RWMutex // guards decks and dirs
+)
 
-func scanDecks(dir string, prefix string) {
+func scanDecks(dir string, prefix string, decks map[string]*Deck) {
 	dirListing, err := os.ReadDir(dir)
 	if err != nil {
 		panic(err)
@@ -56,7 +61,7 @@ func scanDecks(dir string, prefix string) {
 				fmt.Fprintf(os.Stderr, "[deck] Skipping directory %s because it contains a dot.\n", name)
 				continue
 			}
-			scanDecks(dir+"/"+name, prefix+name+".")
+			scanDecks(dir+"/"+name, prefix+name+".", decks)
 			continue
 		}
 
@@ -125,14 +130,43 @@ func scanDecks(dir string, prefix string) {
 
 // InitDecks looks for decks in the given directory, recursively, and loads them into memory.
 func InitDecks(dir string) map[string]*Deck {
-	scanDecks(dir, "")
+	decksMu.Lock()
+	defer decksMu.Unlock()
+	scanDecks(dir, "", decks)
+	dirs = append(dirs, dir)
 	return decks
 }
 
+// ReloadDecks scans the directories passed to InitDecks again and replaces the
+// loaded decks. If a deck fails to load, the current decks are kept. Rooms
+// keep using the decks they already selected.
+func ReloadDecks() (count int, err error) {
+	decksMu.RLock()
+	reloadDirs := append([]string{}, dirs...)
+	decksMu.RUnlock()
+
+	defer func() {
+		// scanDecks panics on invalid decks
+		if v := recover(); v != nil {
+			err = fmt.Errorf("failed to reload decks: %v", v)
+		}
+	}()
+
+	reloaded := make(map[string]*Deck)
+	for _, dir := range reloadDirs {
+		scanDecks(dir, "", reloaded)
+	}
+
+	decksMu.Lock()
+	decks = reloaded
+	decksMu.Unlock()
+	return len(reloaded), nil
+}
+
 // InitDecksOnce is like InitDecks, but it only will run if the decks haven't been loaded yet.
 func InitDecksOnce(dir string) map[string]*Deck {
-	if len(decks) > 0 {
-		return decks
+	if len(Decks()) > 0 {
+		return Decks()
 	}
 
 	return InitDecks(dir)
@@ -140,5 +174,7 @@ func InitDecksOnce(dir string) map[string]*Deck {
 
 // Decks returns the map of decks.
 func Decks() map[string]*Deck {
+	decksMu.RLock()
+	defer decksMu.RUnlock()
 	return decks
 }
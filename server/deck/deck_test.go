@@ -15,3 +15,15 @@ func TestDecks(t *testing.T) {
 	}
 	os.WriteFile("./.decks.json", j, 0644)
 }
+
+func TestReloadDecks(t *testing.T) {
+	loaded := len(InitDecksOnce("../data/decks"))
+
+	count, err := ReloadDecks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != loaded || len(Decks()) != loaded {
+		t.Errorf("reloaded %d decks, want %d", count, loaded)
+	}
+}
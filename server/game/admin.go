@@ -0,0 +1,95 @@
+package game
+
+import (
+	"cardgame/card"
+	"time"
+)
+
+// RoomInspection is the full state of a room, including the parts hidden
+// from players, for the admin API.
+type RoomInspection struct {
+	Room          any                 `json:"room"` // the state sent to players
+	Private       bool                `json:"private"`
+	DrawPile      []card.BaseCard     `json:"drawPile"`
+	UsedWildCards []*card.WildCard    `json:"usedWildCards"`
+	ChatHistory   []*ServerChat       `json:"chatHistory"`
+	Bans          int                 `json:"bans"`      // number of banned identities
+	KickVotes     map[string][]string `json:"kickVotes"` // player id -> ids of players voting to kick them
+	Countdown     bool                `json:"countdown"` // true while an auto-start countdown is running
+}
+
+// Inspect returns the full state of the room.
+func (r *Room) Inspect() (*RoomInspection, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, err := roomState(r)
+	if err != nil {
+		return nil, err
+	}
+
+	kickVotes := map[string][]string{}
+	for id, votes := range r.kickVotes {
+		for voter := range votes {
+			kickVotes[id] = append(kickVotes[id], voter)
+		}
+	}
+
+	return &RoomInspection{
+		Room:          state,
+		Private:       r.private,
+		DrawPile:      append([]card.BaseCard{}, r.drawPile...),
+		UsedWildCards: append([]*card.WildCard{}, r.usedWildCards...),
+		ChatHistory:   append([]*ServerChat{}, r.chatHistory...),
+		Bans:          len(r.bans),
+		KickVotes:     kickVotes,
+		Countdown:     r.counting,
+	}, nil
+}
+
+// Kick removes a player or spectator from the room, optionally banning them.
+// Unlike HandleKick, it does not require the owner.
+func (r *Room) Kick(id string, ban bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return newError(ErrorRoomNotFound, "Room not found")
+	}
+
+	target := r.getMember(id)
+	if target == nil {
+		return newError(ErrorPlayerNotFound, "player not found").with("id", id)
+	}
+
+	r.kick(target, ban)
+	return nil
+}
+
+// CloseRoom removes the room from the hub and closes it, sending all of its
+// players back to the hub.
+func (h *Hub) CloseRoom(id string, reason string) error {
+	h.mu.Lock()
+	r := h.Rooms[id]
+	delete(h.Rooms, id)
+	h.mu.Unlock()
+
+	if r == nil {
+		return newError(ErrorRoomNotFound, "Room not found")
+	}
+	r.close(reason)
+	return nil
+}
+
+// Announce sends a message to every connected player and returns the number
+// of players it was sent to.
+func (h *Hub) Announce(message string) int {
+	players := h.Players()
+	announcement := &ServerAnnouncement{
+		Timestamp: time.Now().UnixMilli(),
+		Message:   message,
+	}
+	for _, p := range players {
+		p.send(announcement)
+	}
+	return len(players)
+}
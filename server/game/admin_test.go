@@ -0,0 +1,47 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloseRoom(t *testing.T) {
+	owner := newTestPlayer("p_owner")
+	other := newTestPlayer("p_other")
+	r := newTestRoom(t, owner, other)
+
+	assert.NoError(t, HubMain.CloseRoom(r.Id, "maintenance"))
+	assert.Nil(t, HubMain.Room(r.Id))
+	assert.Equal(t, "maintenance", receive[*ServerRoomClosed](t, other).Reason)
+	assert.Nil(t, other.room, "players return to the hub")
+	assert.Empty(t, r.Players)
+
+	// messages for the closed room are dropped instead of blocking
+	r.post(ClientChat{Player: owner, Message: "hello?"})
+
+	err := HubMain.CloseRoom(r.Id, "again")
+	assert.Equal(t, ErrorRoomNotFound, err.(*Error).Code)
+}
+
+func TestAdminKick(t *testing.T) {
+	owner := newTestPlayer("p_owner")
+	other := newTestPlayer("p_other")
+	r := newTestRoom(t, owner, other)
+
+	err := r.Kick("p_missing", false)
+	assert.Equal(t, ErrorPlayerNotFound, err.(*Error).Code)
+
+	assert.NoError(t, r.Kick(owner.Id, true), "admins can kick the owner")
+	assert.True(t, receive[*ServerKick](t, owner).Banned)
+	assert.Equal(t, other.Id, r.OwnerId)
+}
+
+func TestAnnounce(t *testing.T) {
+	p := newTestPlayer("p_announce")
+	HubMain.addPlayer(p)
+	defer HubMain.removePlayer(p)
+
+	assert.GreaterOrEqual(t, HubMain.Announce("maintenance soon"), 1)
+	assert.Equal(t, "maintenance soon", receive[*ServerAnnouncement](t, p).Message)
+}
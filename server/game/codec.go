@@ -0,0 +1,75 @@
+package game
+
+import (
+	"bytes"
+	"cardgame/util/msgpack"
+	"encoding/json"
+
+	"github.com/gorilla/websocket"
+)
+
+// Websocket subprotocols selecting the wire format of a connection.
+// Connections without a subprotocol use JSON.
+const (
+	SubprotocolJSON    = "cardgame.json"
+	SubprotocolMsgpack = "cardgame.msgpack"
+)
+
+// Subprotocols are the subprotocols accepted by the server, in order of preference.
+var Subprotocols = []string{SubprotocolMsgpack, SubprotocolJSON}
+
+// codec converts messages between their JSON form, which defines the message
+// types, and the wire format of a connection.
+type codec interface {
+	// encode converts an outgoing message into a websocket message.
+	encode(m map[string]any) (messageType int, data []byte, err error)
+	// decode converts an incoming websocket message into JSON.
+	decode(data []byte) ([]byte, error)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) encode(m map[string]any) (int, []byte, error) {
+	data, err := json.Marshal(m)
+	return websocket.TextMessage, data, err
+}
+
+func (jsonCodec) decode(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+// msgpackCodec encodes the same values as jsonCodec with MessagePack.
+type msgpackCodec struct{}
+
+func (msgpackCodec) encode(m map[string]any) (int, []byte, error) {
+	// normalize to generic values so json tags and marshalers are respected
+	data, err := json.Marshal(m)
+	if err != nil {
+		return 0, nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return 0, nil, err
+	}
+
+	data, err = msgpack.Marshal(v)
+	return websocket.BinaryMessage, data, err
+}
+
+func (msgpackCodec) decode(data []byte) ([]byte, error) {
+	v, err := msgpack.Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// codecFor returns the codec for the subprotocol negotiated on the socket.
+func codecFor(socket *websocket.Conn) codec {
+	if socket != nil && socket.Subprotocol() == SubprotocolMsgpack {
+		return msgpackCodec{}
+	}
+	return jsonCodec{}
+}
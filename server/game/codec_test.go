@@ -0,0 +1,114 @@
+package game
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testCodecs = map[string]codec{
+	SubprotocolJSON:    jsonCodec{},
+	SubprotocolMsgpack: msgpackCodec{},
+}
+
+// fillSample sets every exported field reachable from v to a non-zero value.
+func fillSample(v reflect.Value, depth int) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString("sample")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(3)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1.5)
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Ptr:
+		if depth > 3 {
+			return
+		}
+		e := reflect.New(v.Type().Elem())
+		fillSample(e.Elem(), depth+1)
+		v.Set(e)
+	case reflect.Slice:
+		s := reflect.MakeSlice(v.Type(), 1, 1)
+		fillSample(s.Index(0), depth+1)
+		v.Set(s)
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		key := reflect.New(v.Type().Key()).Elem()
+		value := reflect.New(v.Type().Elem()).Elem()
+		fillSample(key, depth+1)
+		fillSample(value, depth+1)
+		m.SetMapIndex(key, value)
+		v.Set(m)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !v.Field(i).CanSet() || field.Tag.Get("json") == "-" {
+				continue
+			}
+			fillSample(v.Field(i), depth+1)
+		}
+	}
+}
+
+func TestClientMessageRoundTrip(t *testing.T) {
+	p := &Player{}
+
+	for name, typ := range ClientMessageTypes {
+		v := reflect.New(reflect.TypeOf(typ)).Elem()
+		fillSample(v, 0)
+
+		data, err := json.Marshal(v.Interface())
+		assert.NoError(t, err)
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(data, &m))
+		m["type"] = name
+		m["requestId"] = "req-1"
+
+		// the sender and request id are taken from the envelope
+		v.FieldByName("Player").Set(reflect.ValueOf(p))
+		v.FieldByName("RequestId").SetString("req-1")
+		want := v.Interface()
+
+		for protocol, c := range testCodecs {
+			_, wire, err := c.encode(m)
+			assert.NoError(t, err, "%s: %s", protocol, name)
+			decoded, err := c.decode(wire)
+			assert.NoError(t, err, "%s: %s", protocol, name)
+			got, err := p.ClientMessageFromJson(decoded)
+			assert.NoError(t, err, "%s: %s", protocol, name)
+			assert.Equal(t, want, got, "%s: %s", protocol, name)
+		}
+	}
+}
+
+func TestServerMessageRoundTrip(t *testing.T) {
+	for name, typ := range ServerMessageTypes {
+		v := reflect.New(reflect.TypeOf(typ))
+		fillSample(v.Elem(), 0)
+		message := v.Interface().(ServerMessage)
+
+		for protocol, c := range testCodecs {
+			p := &Player{codec: c}
+			sent, err := p.encode(message, nil)
+			assert.NoError(t, err, "%s: %s", protocol, name)
+			decoded, err := c.decode(sent.data)
+			assert.NoError(t, err, "%s: %s", protocol, name)
+
+			var envelope struct {
+				Type string `json:"type"`
+				Seq  int    `json:"seq"`
+			}
+			assert.NoError(t, json.Unmarshal(decoded, &envelope))
+			assert.Equal(t, name, envelope.Type, "%s: %s", protocol, name)
+			assert.Equal(t, 1, envelope.Seq, "%s: %s", protocol, name)
+
+			got := reflect.New(reflect.TypeOf(typ))
+			assert.NoError(t, json.Unmarshal(decoded, got.Interface()), "%s: %s", protocol, name)
+			assert.Equal(t, message, got.Interface(), "%s: %s", protocol, name)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package game
+
+import (
+	"cardgame/deck"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Command is a chat command, run by sending "/<name> [args...]" in the room
+// chat. Commands that change the room go through the same handlers, and so
+// the same permission checks, as the corresponding client messages.
+type Command struct {
+	Name        string
+	Args        string // argument syntax shown in the usage, e.g. "<name>"
+	Description string
+	Run         func(c *CommandContext) error
+}
+
+// Usage returns the usage line of the command.
+func (cmd *Command) Usage() string {
+	if cmd.Args == "" {
+		return "/" + cmd.Name
+	}
+	return "/" + cmd.Name + " " + cmd.Args
+}
+
+// CommandContext is passed to a running command. The room is locked while the
+// command runs.
+type CommandContext struct {
+	Room      *Room
+	Player    *Player
+	Command   *Command
+	Args      []string
+	RequestId string
+}
+
+// Reply sends a private system message to the player running the command.
+func (c *CommandContext) Reply(format string, args ...any) {
+	chat := c.Room.newChat("", fmt.Sprintf(format, args...))
+	chat.Private = true
+	c.Player.send(chat)
+}
+
+// Announce sends a system message to all players in the room.
+func (c *CommandContext) Announce(format string, args ...any) {
+	c.Room.broadcastChat(c.Room.newChat("", fmt.Sprintf(format, args...)))
+}
+
+// UsageError returns the error for a command called with invalid arguments.
+func (c *CommandContext) UsageError() error {
+	return newError(ErrorCommandUsage, "Usage: "+c.Command.Usage()).with("usage", c.Command.Usage())
+}
+
+var (
+	commands   = map[string]*Command{}
+	commandsMu sync.RWMutex // guards commands, which rooms read concurrently
+)
+
+// RegisterCommand adds a chat command, replacing any command with the same name.
+// It is safe to call while rooms are running commands.
+func RegisterCommand(cmd *Command) {
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+	commands[strings.ToLower(cmd.Name)] = cmd
+}
+
+// Commands returns all registered chat commands sorted by name.
+func Commands() []*Command {
+	commandsMu.RLock()
+	list := make([]*Command, 0, len(commands))
+	for _, cmd := range commands {
+		list = append(list, cmd)
+	}
+	commandsMu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// runCommand runs the chat command in message, which starts with a slash.
+func (r *Room) runCommand(message ClientChat) error {
+	fields := strings.Fields(message.Message[1:])
+	if len(fields) == 0 {
+		return newError(ErrorUnknownCommand, "Unknown command, try /help")
+	}
+
+	commandsMu.RLock()
+	cmd, ok := commands[strings.ToLower(fields[0])]
+	commandsMu.RUnlock()
+	if !ok {
+		return newError(ErrorUnknownCommand, "Unknown command, try /help").with("command", fields[0])
+	}
+
+	return cmd.Run(&CommandContext{
+		Room:      r,
+		Player:    message.Player,
+		Command:   cmd,
+		Args:      fields[1:],
+		RequestId: message.RequestId,
+	})
+}
+
+// findPlayer returns the player or spectator with the given id or name. Names
+// are matched case-insensitively.
+func (r *Room) findPlayer(query string) (*Player, error) {
+	if p := r.getMember(query); p != nil {
+		return p, nil
+	}
+
+	var found *Player
+	for _, p := range r.members() {
+		if strings.EqualFold(p.Name, query) {
+			if found != nil {
+				return nil, newError(ErrorAmbiguousPlayer, "More than one player is called "+query).with("name", query)
+			}
+			found = p
+		}
+	}
+	if found == nil {
+		return nil, newError(ErrorPlayerNotFound, "No player called "+query).with("name", query)
+	}
+	return found, nil
+}
+
+// findDeck returns the deck with the given id or name. Names are matched
+// case-insensitively.
+func findDeck(query string) (*deck.Deck, error) {
+	decks := deck.Decks()
+	if d, ok := decks[query]; ok {
+		return d, nil
+	}
+	for _, d := range decks {
+		if strings.EqualFold(d.Name, query) {
+			return d, nil
+		}
+	}
+	return nil, newError(ErrorDeckNotFound, "No deck called "+query).with("name", query)
+}
+
+const (
+	defaultMuteDuration = 5 * 60 // seconds
+	defaultRollSides    = 6
+	maxRollSides        = 1000
+)
+
+func init() {
+	RegisterCommand(&Command{
+		Name:        "help",
+		Description: "List the available commands",
+		Run: func(c *CommandContext) error {
+			lines := []string{"Commands:"}
+			for _, cmd := range Commands() {
+				lines = append(lines, fmt.Sprintf("%s - %s", cmd.Usage(), cmd.Description))
+			}
+			c.Reply("%s", strings.Join(lines, "\n"))
+			return nil
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:        "kick",
+		Args:        "<name>",
+		Description: "Kick a player from the room",
+		Run: func(c *CommandContext) error {
+			if len(c.Args) == 0 {
+				return c.UsageError()
+			}
+			target, err := c.Room.findPlayer(strings.Join(c.Args, " "))
+			if err != nil {
+				return err
+			}
+			return c.Room.HandleKick(ClientKick{Player: c.Player, RequestId: c.RequestId, Id: target.Id})
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:        "ban",
+		Args:        "<name>",
+		Description: "Kick a player and prevent them from rejoining",
+		Run: func(c *CommandContext) error {
+			if len(c.Args) == 0 {
+				return c.UsageError()
+			}
+			target, err := c.Room.findPlayer(strings.Join(c.Args, " "))
+			if err != nil {
+				return err
+			}
+			return c.Room.HandleKick(ClientKick{Player: c.Player, RequestId: c.RequestId, Id: target.Id, Ban: true})
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:        "votekick",
+		Args:        "<name>",
+		Description: "Vote to kick a player while the owner is away",
+		Run: func(c *CommandContext) error {
+			if len(c.Args) == 0 {
+				return c.UsageError()
+			}
+			target, err := c.Room.findPlayer(strings.Join(c.Args, " "))
+			if err != nil {
+				return err
+			}
+			return c.Room.HandleVoteKick(ClientVoteKick{Player: c.Player, RequestId: c.RequestId, Id: target.Id})
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:        "start",
+		Description: "Start the game",
+		Run: func(c *CommandContext) error {
+			return c.Room.HandleStart(ClientStart{Player: c.Player, RequestId: c.RequestId})
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:        "pause",
+		Description: "Pause the game",
+		Run: func(c *CommandContext) error {
+			return c.Room.HandlePause(ClientPause{Player: c.Player, RequestId: c.RequestId})
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:        "resume",
+		Description: "Resume the paused game",
+		Run: func(c *CommandContext) error {
+			return c.Room.HandleResume(ClientResume{Player: c.Player, RequestId: c.RequestId})
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:        "mute",
+		Args:        "<name> [seconds]",
+		Description: fmt.Sprintf("Mute a player, for %d seconds by default or 0 to unmute", defaultMuteDuration),
+		Run: func(c *CommandContext) error {
+			args := c.Args
+			duration := defaultMuteDuration
+			if len(args) > 1 {
+				if n, err := strconv.Atoi(args[len(args)-1]); err == nil {
+					duration = n
+					args = args[:len(args)-1]
+				}
+			}
+			if len(args) == 0 {
+				return c.UsageError()
+			}
+
+			target, err := c.Room.findPlayer(strings.Join(args, " "))
+			if err != nil {
+				return err
+			}
+			message := ClientMute{Player: c.Player, RequestId: c.RequestId, Id: target.Id, Duration: duration}
+			if err := message.validate(c.Room.hub.config); err != nil {
+				return err
+			}
+			return c.Room.HandleMute(message)
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:        "roll",
+		Args:        "[sides]",
+		Description: fmt.Sprintf("Roll a die with %d sides by default", defaultRollSides),
+		Run: func(c *CommandContext) error {
+			sides := defaultRollSides
+			if len(c.Args) > 1 {
+				return c.UsageError()
+			}
+			if len(c.Args) == 1 {
+				n, err := strconv.Atoi(c.Args[0])
+				if err != nil || n < 2 || n > maxRollSides {
+					return c.UsageError()
+				}
+				sides = n
+			}
+			c.Announce("%s rolled %d (1-%d)", c.Player.Name, rand.Intn(sides)+1, sides)
+			return nil
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:        "decks",
+		Args:        "[add|remove <name>]",
+		Description: "List the decks in the room, or add or remove a deck",
+		Run: func(c *CommandContext) error {
+			if len(c.Args) == 0 {
+				if len(c.Room.Decks) == 0 {
+					c.Reply("No decks selected")
+					return nil
+				}
+				names := make([]string, len(c.Room.Decks))
+				for i, d := range c.Room.Decks {
+					names[i] = d.Name
+				}
+				c.Reply("Decks: %s", strings.Join(names, ", "))
+				return nil
+			}
+			if len(c.Args) < 2 {
+				return c.UsageError()
+			}
+
+			d, err := findDeck(strings.Join(c.Args[1:], " "))
+			if err != nil {
+				return err
+			}
+			message := ClientChangeDetails{Player: c.Player, RequestId: c.RequestId}
+			switch strings.ToLower(c.Args[0]) {
+			case "add":
+				message.AddDecks = []string{d.Id}
+			case "remove":
+				message.RemoveDecks = []string{d.Id}
+			default:
+				return c.UsageError()
+			}
+			return c.Room.HandleChangeDetails(message)
+		},
+	})
+
+	RegisterCommand(&Command{
+		Name:        "transfer",
+		Args:        "<name>",
+		Description: "Make another player the owner of the room",
+		Run: func(c *CommandContext) error {
+			if len(c.Args) == 0 {
+				return c.UsageError()
+			}
+			target, err := c.Room.findPlayer(strings.Join(c.Args, " "))
+			if err != nil {
+				return err
+			}
+			return c.Room.HandleTransferOwnership(ClientTransferOwnership{Player: c.Player, RequestId: c.RequestId, Id: target.Id})
+		},
+	})
+}
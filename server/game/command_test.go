@@ -0,0 +1,113 @@
+package game
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func chat(p *Player, text string) ClientChat {
+	return ClientChat{Player: p, Message: text}
+}
+
+func TestCommands(t *testing.T) {
+	owner := newTestPlayer("p_owner")
+	owner.Name = "Owner"
+	other := newTestPlayer("p_other")
+	other.Name = "Happy Otter"
+	r := newTestRoom(t, owner, other)
+
+	assert.NoError(t, r.HandleChat(chat(other, "/help")))
+	help := receive[*ServerChat](t, other)
+	assert.True(t, help.System)
+	assert.True(t, help.Private)
+	for _, cmd := range []string{"/kick", "/start", "/mute", "/roll", "/decks", "/transfer", "/help"} {
+		assert.Contains(t, help.Message, cmd)
+	}
+
+	err := r.HandleChat(chat(other, "/dance"))
+	assert.Equal(t, ErrorUnknownCommand, err.(*Error).Code)
+
+	err = r.HandleChat(chat(other, "/kick owner"))
+	assert.Equal(t, ErrorNotOwner, err.(*Error).Code, "commands use the same permission checks")
+
+	err = r.HandleChat(chat(owner, "/kick"))
+	assert.Equal(t, ErrorCommandUsage, err.(*Error).Code)
+	err = r.HandleChat(chat(owner, "/kick nobody"))
+	assert.Equal(t, ErrorPlayerNotFound, err.(*Error).Code)
+
+	assert.NoError(t, r.HandleChat(chat(owner, "/mute happy otter 30")))
+	assert.Equal(t, other.Id, receive[*ServerMute](t, other).PlayerId)
+	err = r.HandleChat(chat(owner, "/mute happy otter -1"))
+	assert.Equal(t, "duration", err.(*Error).Details["field"])
+
+	assert.NoError(t, r.HandleChat(chat(owner, "/roll 20")))
+	roll := receive[*ServerChat](t, other)
+	assert.True(t, roll.System)
+	assert.False(t, roll.Private)
+	assert.True(t, strings.HasPrefix(roll.Message, "Owner rolled "), roll.Message)
+
+	assert.NoError(t, r.HandleChat(chat(owner, "//roll")))
+	assert.Equal(t, "/roll", receive[*ServerChat](t, other).Message, "double slash escapes commands")
+
+	assert.NoError(t, r.HandleChat(chat(owner, "/transfer Happy Otter")))
+	assert.Equal(t, other.Id, r.OwnerId)
+}
+
+func TestCommandChecks(t *testing.T) {
+	owner := newTestPlayer("p_owner")
+	other := newTestPlayer("p_other")
+	spectator := newTestPlayer("p_spectator")
+	spectator.Name = "Quiet Heron"
+	r := newTestRoom(t, owner, other)
+	r.Spectators = []*Player{spectator}
+	spectator.room = r
+
+	assert.NoError(t, r.HandleChat(chat(owner, "/mute quiet heron")), "spectators can be muted")
+	assert.NotZero(t, spectator.MutedUntil)
+	err := r.HandleChat(chat(spectator, "/roll"))
+	assert.Equal(t, ErrorMuted, err.(*Error).Code, "muted players cannot run commands")
+
+	r.SlowMode = 60
+	assert.NoError(t, r.HandleChat(chat(other, "/roll")))
+	err = r.HandleChat(chat(other, "/roll"))
+	assert.Equal(t, ErrorSlowMode, err.(*Error).Code, "slow mode applies to commands")
+
+	assert.NoError(t, r.HandleChat(chat(owner, "/kick Quiet Heron")), "spectators can be kicked")
+	assert.Nil(t, r.getMember(spectator.Id))
+}
+
+// registerTestCommand registers a command for the duration of the test.
+func registerTestCommand(t *testing.T, cmd *Command) {
+	t.Helper()
+	RegisterCommand(cmd)
+	t.Cleanup(func() {
+		commandsMu.Lock()
+		defer commandsMu.Unlock()
+		delete(commands, strings.ToLower(cmd.Name))
+	})
+}
+
+func TestRegisterCommand(t *testing.T) {
+	registerTestCommand(t, &Command{
+		Name: "echo",
+		Args: "<text>",
+		Run: func(c *CommandContext) error {
+			if len(c.Args) == 0 {
+				return c.UsageError()
+			}
+			c.Reply("%s", strings.Join(c.Args, " "))
+			return nil
+		},
+	})
+
+	p := newTestPlayer("p_owner")
+	r := newTestRoom(t, p)
+
+	assert.NoError(t, r.HandleChat(chat(p, "/ECHO hello  world")))
+	assert.Equal(t, "hello world", receive[*ServerChat](t, p).Message)
+
+	err := r.HandleChat(chat(p, "/echo"))
+	assert.Equal(t, "/echo <text>", err.(*Error).Details["usage"])
+}
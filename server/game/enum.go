@@ -8,6 +8,11 @@ const (
 	PlayModeHubOnly
 )
 
+// Valid returns true if the play mode is one of the defined play modes.
+func (m PlayMode) Valid() bool {
+	return m >= PlayModePlayersOnly && m <= PlayModeHubOnly
+}
+
 var TSAllPlayModes = []struct {
 	Value  PlayMode
 	TSName string
@@ -17,6 +22,28 @@ var TSAllPlayModes = []struct {
 	{PlayModeHubOnly, "HubOnly"},
 }
 
+// LeaveCards decides what happens to the cards of a player who leaves during
+// the game.
+type LeaveCards int
+
+const (
+	LeaveCardsDrawPile LeaveCards = iota // shuffle them into the draw pile
+	LeaveCardsDiscard                    // remove them from the game
+)
+
+// Valid returns true if the value is one of the defined options.
+func (l LeaveCards) Valid() bool {
+	return l >= LeaveCardsDrawPile && l <= LeaveCardsDiscard
+}
+
+var TSAllLeaveCards = []struct {
+	Value  LeaveCards
+	TSName string
+}{
+	{LeaveCardsDrawPile, "DrawPile"},
+	{LeaveCardsDiscard, "Discard"},
+}
+
 type GamePhase int
 
 const (
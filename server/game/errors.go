@@ -0,0 +1,150 @@
+package game
+
+import "reflect"
+
+// ErrorCode identifies the kind of error in a ServerError. Codes are stable,
+// so clients can match on them instead of the message.
+type ErrorCode string
+
+const (
+	ErrorInternal          ErrorCode = "internal"           // unexpected server error
+	ErrorInvalidMessage    ErrorCode = "invalid_message"    // the message could not be parsed or failed validation
+	ErrorRateLimited       ErrorCode = "rate_limited"       // too many messages of this type
+	ErrorAlreadyGreeted    ErrorCode = "already_greeted"    // hello was sent twice on a connection
+	ErrorAlreadyInRoom     ErrorCode = "already_in_room"    // the player is already in a room
+	ErrorNotInRoom         ErrorCode = "not_in_room"        // the action requires being in a room
+	ErrorRoomNotFound      ErrorCode = "room_not_found"     // no room with the given id
+	ErrorIncorrectPassword ErrorCode = "incorrect_password" // wrong password for a private room
+	ErrorRoomFull          ErrorCode = "room_full"          // the room has reached its maximum number of players
+	ErrorInvalidSession    ErrorCode = "invalid_session"    // reconnect with an unknown player or wrong token
+	ErrorNotOwner          ErrorCode = "not_owner"          // the action requires the room owner
+	ErrorKickSelf          ErrorCode = "kick_self"          // the owner tried to kick themselves
+	ErrorPlayerNotFound    ErrorCode = "player_not_found"   // no player with the given id in the room
+	ErrorGameStarted       ErrorCode = "game_started"       // the action is only possible in the lobby
+	ErrorGameNotPlaying    ErrorCode = "game_not_playing"   // the action is only possible while playing
+	ErrorNotYourTurn       ErrorCode = "not_your_turn"      // the action is only possible on the player's turn
+	ErrorDrawPileEmpty     ErrorCode = "draw_pile_empty"    // there are no cards left to draw
+	ErrorIncompatibleCards ErrorCode = "incompatible_cards" // the top cards do not match
+	ErrorMuted             ErrorCode = "muted"              // the player is muted
+	ErrorSlowMode          ErrorCode = "slow_mode"          // the player sent a chat message too soon after the last one
+	ErrorMessageNotFound   ErrorCode = "message_not_found"  // no chat message with the given id
+	ErrorUnknownCommand    ErrorCode = "unknown_command"    // no chat command with the given name
+	ErrorCommandUsage      ErrorCode = "command_usage"      // a chat command was called with invalid arguments
+	ErrorAmbiguousPlayer   ErrorCode = "ambiguous_player"   // more than one player matches the given name
+	ErrorDeckNotFound      ErrorCode = "deck_not_found"     // no deck with the given name
+	ErrorBanned            ErrorCode = "banned"             // the player is banned from the room
+	ErrorOwnerPresent      ErrorCode = "owner_present"      // vote-kicks are only allowed while the owner is absent
+	ErrorSpectating        ErrorCode = "spectating"         // spectators cannot play until the next game
+	ErrorNotEnoughPlayers  ErrorCode = "not_enough_players" // the game needs more players to start
+	ErrorNoDecks           ErrorCode = "no_decks"           // the game needs at least one deck to start
+	ErrorNotEnoughCards    ErrorCode = "not_enough_cards"   // the selected decks do not have enough cards for the players
+	ErrorPlayersNotReady   ErrorCode = "players_not_ready"  // some players are not ready
+	ErrorGamePaused        ErrorCode = "game_paused"        // the game is paused
+	ErrorGameNotPaused     ErrorCode = "game_not_paused"    // the game is not paused
+	ErrorShuttingDown      ErrorCode = "shutting_down"      // the server is shutting down and does not accept new games
+)
+
+var TSAllErrorCodes = []struct {
+	Value  ErrorCode
+	TSName string
+}{
+	{ErrorInternal, "Internal"},
+	{ErrorInvalidMessage, "InvalidMessage"},
+	{ErrorRateLimited, "RateLimited"},
+	{ErrorAlreadyGreeted, "AlreadyGreeted"},
+	{ErrorAlreadyInRoom, "AlreadyInRoom"},
+	{ErrorNotInRoom, "NotInRoom"},
+	{ErrorRoomNotFound, "RoomNotFound"},
+	{ErrorIncorrectPassword, "IncorrectPassword"},
+	{ErrorRoomFull, "RoomFull"},
+	{ErrorInvalidSession, "InvalidSession"},
+	{ErrorNotOwner, "NotOwner"},
+	{ErrorKickSelf, "KickSelf"},
+	{ErrorPlayerNotFound, "PlayerNotFound"},
+	{ErrorGameStarted, "GameStarted"},
+	{ErrorGameNotPlaying, "GameNotPlaying"},
+	{ErrorNotYourTurn, "NotYourTurn"},
+	{ErrorDrawPileEmpty, "DrawPileEmpty"},
+	{ErrorIncompatibleCards, "IncompatibleCards"},
+	{ErrorMuted, "Muted"},
+	{ErrorSlowMode, "SlowMode"},
+	{ErrorMessageNotFound, "MessageNotFound"},
+	{ErrorUnknownCommand, "UnknownCommand"},
+	{ErrorCommandUsage, "CommandUsage"},
+	{ErrorAmbiguousPlayer, "AmbiguousPlayer"},
+	{ErrorDeckNotFound, "DeckNotFound"},
+	{ErrorBanned, "Banned"},
+	{ErrorOwnerPresent, "OwnerPresent"},
+	{ErrorSpectating, "Spectating"},
+	{ErrorNotEnoughPlayers, "NotEnoughPlayers"},
+	{ErrorNoDecks, "NoDecks"},
+	{ErrorNotEnoughCards, "NotEnoughCards"},
+	{ErrorPlayersNotReady, "PlayersNotReady"},
+	{ErrorGamePaused, "GamePaused"},
+	{ErrorGameNotPaused, "GameNotPaused"},
+	{ErrorShuttingDown, "ShuttingDown"},
+}
+
+// Error is an error caused by a client message. It is sent back to the
+// client as a ServerError.
+type Error struct {
+	Code    ErrorCode
+	Message string
+	Details map[string]any
+}
+
+func (e *Error) Error() string { return string(e.Code) + ": " + e.Message }
+
+// newError returns an Error with the given code and message.
+func newError(code ErrorCode, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// with adds a detail to the error and returns it.
+func (e *Error) with(key string, value any) *Error {
+	if e.Details == nil {
+		e.Details = make(map[string]any)
+	}
+	e.Details[key] = value
+	return e
+}
+
+// serverError converts an error caused by a client message into a ServerError.
+// Errors that are not an *Error are reported as internal errors.
+func serverError(message ClientMessage, err error) *ServerError {
+	e, ok := err.(*Error)
+	if !ok {
+		e = newError(ErrorInternal, err.Error())
+	}
+
+	errorsSent.With(string(e.Code)).Inc()
+	s := &ServerError{
+		Code:    e.Code,
+		Message: e.Message,
+		Details: e.Details,
+	}
+	if message != nil {
+		s.Request = message.ClientType()
+		s.RequestId = messageRequestId(message)
+	}
+	return s
+}
+
+// messagePlayer returns the player who sent a client message.
+func messagePlayer(message ClientMessage) *Player {
+	f := reflect.ValueOf(message).FieldByName("Player")
+	if !f.IsValid() {
+		return nil
+	}
+	p, _ := f.Interface().(*Player)
+	return p
+}
+
+// messageRequestId returns the request id of a client message, or "" for
+// internal messages.
+func messageRequestId(message ClientMessage) string {
+	if f := reflect.ValueOf(message).FieldByName("RequestId"); f.IsValid() {
+		return f.String()
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package game
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerError(t *testing.T) {
+	message := ClientKick{Id: "p_missing", RequestId: "req-7"}
+	e := serverError(message, newError(ErrorPlayerNotFound, "player not found").with("id", "p_missing"))
+	assert.Equal(t, ErrorPlayerNotFound, e.Code)
+	assert.Equal(t, "player not found", e.Message)
+	assert.Equal(t, map[string]any{"id": "p_missing"}, e.Details)
+	assert.Equal(t, "kick", e.Request)
+	assert.Equal(t, "req-7", e.RequestId)
+
+	e = serverError(nil, errors.New("something broke"))
+	assert.Equal(t, ErrorInternal, e.Code)
+	assert.Empty(t, e.Request)
+}
+
+func TestErrorCodesExported(t *testing.T) {
+	exported := map[ErrorCode]string{}
+	for _, c := range TSAllErrorCodes {
+		_, duplicate := exported[c.Value]
+		assert.False(t, duplicate, "duplicate error code %s", c.Value)
+		exported[c.Value] = c.TSName
+	}
+
+	// every ErrorCode constant declared in errors.go must be exported, under
+	// its name without the Error prefix
+	file, err := parser.ParseFile(token.NewFileSet(), "errors.go", nil, 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	declared := 0
+	for _, d := range file.Decls {
+		decl, ok := d.(*ast.GenDecl)
+		if !ok || decl.Tok != token.CONST {
+			continue
+		}
+		for _, s := range decl.Specs {
+			spec := s.(*ast.ValueSpec)
+			if typ, ok := spec.Type.(*ast.Ident); !ok || typ.Name != "ErrorCode" {
+				continue
+			}
+			for i, name := range spec.Names {
+				lit, ok := spec.Values[i].(*ast.BasicLit)
+				if !assert.True(t, ok, "%s is not a string literal", name.Name) {
+					continue
+				}
+				value, err := strconv.Unquote(lit.Value)
+				assert.NoError(t, err)
+				declared++
+
+				tsName, ok := exported[ErrorCode(value)]
+				if assert.True(t, ok, "%s is missing from TSAllErrorCodes", name.Name) {
+					assert.Equal(t, strings.TrimPrefix(name.Name, "Error"), tsName)
+				}
+			}
+		}
+	}
+	assert.NotZero(t, declared)
+	assert.Len(t, TSAllErrorCodes, declared, "TSAllErrorCodes has codes not declared in errors.go")
+}
+
+func TestMessagePlayer(t *testing.T) {
+	p := &Player{Id: "p_test"}
+	assert.Same(t, p, messagePlayer(ClientDraw{Player: p}))
+	assert.Same(t, p, messagePlayer(playerDisconnected{Player: p}))
+	assert.Equal(t, "req-1", messageRequestId(ClientDraw{RequestId: "req-1"}))
+	assert.Empty(t, messageRequestId(playerDisconnected{Player: p}))
+}
@@ -0,0 +1,43 @@
+package game
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// WordFilter masks blocked words in chat messages and player names.
+type WordFilter struct {
+	pattern *regexp.Regexp // nil if no words are blocked
+}
+
+// NewWordFilter returns a filter for the given words. Words are matched
+// case-insensitively and only as whole words.
+func NewWordFilter(words []string) *WordFilter {
+	quoted := []string{}
+	for _, w := range words {
+		w = strings.TrimSpace(w)
+		if w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+	if len(quoted) == 0 {
+		return &WordFilter{}
+	}
+	return &WordFilter{
+		pattern: regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`),
+	}
+}
+
+// Filter replaces every blocked word in s with asterisks.
+func (f *WordFilter) Filter(s string) string {
+	if f == nil || f.pattern == nil {
+		return s
+	}
+	return f.pattern.ReplaceAllStringFunc(s, func(w string) string {
+		return strings.Repeat("*", utf8.RuneCountInString(w))
+	})
+}
+
+// ChatFilter is applied to chat messages and player names.
+var ChatFilter = NewWordFilter(nil)
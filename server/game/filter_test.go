@@ -0,0 +1,19 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWordFilter(t *testing.T) {
+	f := NewWordFilter([]string{"darn", " heck ", "", "a.b"})
+
+	assert.Equal(t, "well **** it", f.Filter("well darn it"))
+	assert.Equal(t, "****, ****!", f.Filter("HECK, Darn!"))
+	assert.Equal(t, "darned", f.Filter("darned"), "only whole words are filtered")
+	assert.Equal(t, "*** axb", f.Filter("a.b axb"), "words are matched literally")
+
+	assert.Equal(t, "darn", NewWordFilter(nil).Filter("darn"))
+	assert.Equal(t, "darn", (*WordFilter)(nil).Filter("darn"))
+}
@@ -2,98 +2,328 @@ package game
 
 import (
 	"cardgame/card"
+	"cardgame/config"
 	"cardgame/deck"
+	"cardgame/util/logging"
 	"cardgame/util/slices"
 	"math/rand"
+	"strings"
 	"time"
 
 	"fmt"
-	"log"
 )
 
 func (r *Room) HandleMessage(message ClientMessage) {
-	fmt.Printf("room<- %#v\n", message)
+	start := time.Now()
+	p := messagePlayer(message)
+	logger := r.log.With("type", message.ClientType())
+	if p != nil {
+		logger = logger.With("player", p.Id)
+	}
+	if id := messageRequestId(message); id != "" {
+		logger = logger.With("requestId", id)
+	}
+	if logger.Enabled(logging.LevelDebug) {
+		logger.Debug("message received", messageFields(message)...)
+	}
 
+	defer func() {
+		handlerDuration.With(message.ClientType()).Observe(time.Since(start).Seconds())
+	}()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+
+	var err error
 	switch m := message.(type) {
 	case ClientJoin:
-		r.HandleJoin(m)
+		err = r.HandleJoin(m)
 	case ClientLeave:
-		r.HandleLeave(m)
+		err = r.HandleLeave(m)
 	case ClientChangeDetails:
-		r.HandleChangeDetails(m)
+		err = r.HandleChangeDetails(m)
 	case ClientKick:
-		r.HandleKick(m)
+		err = r.HandleKick(m)
+	case ClientVoteKick:
+		err = r.HandleVoteKick(m)
+	case ClientTransferOwnership:
+		err = r.HandleTransferOwnership(m)
+	case ClientReady:
+		err = r.HandleReady(m)
 	case ClientStart:
-		r.HandleStart(m)
+		err = r.HandleStart(m)
+	case ClientPause:
+		err = r.HandlePause(m)
+	case ClientResume:
+		err = r.HandleResume(m)
 	case ClientDraw:
-		r.HandleDraw(m)
+		err = r.HandleDraw(m)
 	case ClientSend:
-		r.HandleSend(m)
+		err = r.HandleSend(m)
 	case ClientChat:
-		r.HandleChat(m)
+		err = r.HandleChat(m)
+	case ClientDeleteChat:
+		err = r.HandleDeleteChat(m)
+	case ClientMute:
+		err = r.HandleMute(m)
+	case ClientRequestSnapshot:
+		err = r.HandleRequestSnapshot(m)
+	case playerDisconnected:
+		r.handleDisconnected(m)
+	case playerReconnected:
+		r.handleReconnected(m)
+	case playerTimedOut:
+		r.handleTimedOut(m)
+	case autoStart:
+		r.handleAutoStart(m)
 	default:
-		fmt.Printf("[error] unhandled message type %T\n", m)
+		logger.Error("unhandled message type")
+	}
+
+	if err != nil {
+		if e, ok := err.(*Error); ok {
+			logger.Debug("message rejected", "code", e.Code, "error", e.Message)
+		} else {
+			logger.Error("message failed", "error", err)
+		}
+		if p != nil {
+			p.send(serverError(message, err))
+		}
+		return
+	}
+
+	if _, ok := ClientMessageTypes[message.ClientType()]; ok && p != nil {
+		p.send(&ServerAck{
+			Action:    message.ClientType(),
+			RequestId: messageRequestId(message),
+		})
 	}
 }
 
-func (r *Room) HandleJoin(message ClientJoin) {
+func (r *Room) HandleJoin(message ClientJoin) error {
 	p := message.Player
-	if r != p.room {
-		log.Println("[error] player is in another room")
-		p.outbound <- &ServerError{"player is in another room"}
-		return
+	if current := p.currentRoom(); current != nil && current != r {
+		return newError(ErrorAlreadyInRoom, "player is in another room")
 	}
 
-	for _, player := range r.Players {
-		if player.Id == p.Id {
-			log.Println("[error] player is already in room")
-			p.outbound <- &ServerError{"player is already in room"}
-			return
+	if r.getMember(p.Id) != nil {
+		return newError(ErrorAlreadyInRoom, "player is already in room")
+	}
+
+	for _, identity := range r.banIdentities(p) {
+		if _, ok := r.bans[identity]; ok {
+			return newError(ErrorBanned, "You are banned from this room")
 		}
 	}
 
-	if r.IsFull() {
-		p.outbound <- &ServerError{"Room is full"}
-		return
+	// during the game, players join the turn order only if the room allows
+	// it and there is space; everyone else watches until the next game
+	spectator := r.GamePhase == GamePhasePlaying && (!r.LateJoin || r.IsFull())
+	if spectator && len(r.Spectators) >= r.hub.config.Rooms.MaxSpectators {
+		return newError(ErrorRoomFull, "Room is full")
+	}
+	if !spectator && r.IsFull() {
+		return newError(ErrorRoomFull, "Room is full")
 	}
 
-	r.Players = append(r.Players, p)
+	if name := strings.TrimSpace(message.Name); name != "" {
+		p.Name = ChatFilter.Filter(name)
+	}
+	p.Ready = false
+
+	switch {
+	case spectator:
+		r.Spectators = append(r.Spectators, p)
+	case r.GamePhase == GamePhasePlaying:
+		// take the seat just before the current player, so the newcomer
+		// plays after everyone else had their turn this round
+		r.Players = append(r.Players[:r.CurrentTurn:r.CurrentTurn], append([]*Player{p}, r.Players[r.CurrentTurn:]...)...)
+		r.CurrentTurn++
+	default:
+		r.Players = append(r.Players, p)
+	}
+	p.setRoom(r)
+	r.joinCounter++
+	p.joined = r.joinCounter
 
 	if len(r.Players) == 1 {
 		// first player becomes owner
 		r.OwnerId = p.Id
 	}
 
-	p.outbound <- &ServerAck{}
-	r.outbound <- &serverPayload{
+	p.send(&ServerSession{
+		PlayerId: p.Id,
+		Token:    p.session,
+	})
+	r.send(p, &ServerSnapshot{})
+	p.send(&ServerChatHistory{
+		Messages: append([]*ServerChat{}, r.chatHistory...),
+	})
+	r.broadcast(&serverPayload{
 		exclude: set{p.Id: {}},
 		message: &ServerJoin{
+			Id:        p.Id,
+			Player:    p,
+			Spectator: spectator,
+		},
+	})
+	r.log.Info("player joined", "player", p.Id, "spectator", spectator)
+	r.updateCountdown()
+	return nil
+}
+
+func (r *Room) HandleLeave(message ClientLeave) error {
+	if r.getMember(message.Player.Id) != message.Player {
+		// already removed, for example by a kick handled in the meantime
+		return newError(ErrorNotInRoom, "You are not in a room")
+	}
+	r.removePlayer(message.Player, false)
+	return nil
+}
+
+// removePlayer removes p from the room and notifies the remaining players.
+// During the game, the turn order is fixed up and the player's cards are
+// returned to the draw pile or discarded, depending on the room settings.
+func (r *Room) removePlayer(p *Player, kicked bool) {
+	index := slices.IndexOf(r.Players, p)
+	r.Players = slices.Remove(r.Players, p)
+	r.Spectators = slices.Remove(r.Spectators, p)
+	p.setRoom(nil)
+	p.send(&playerGone{})
+	r.log.Info("player left", "player", p.Id, "kicked", kicked)
+
+	delete(r.kickVotes, p.Id)
+	for _, votes := range r.kickVotes {
+		delete(votes, p.Id)
+	}
+
+	r.broadcast(&serverPayload{
+		message: &ServerLeave{
 			Id:     p.Id,
-			Player: *p,
+			Kicked: kicked,
 		},
+	})
+
+	if p.Id == r.OwnerId {
+		r.migrateOwner()
 	}
 
+	if r.GamePhase == GamePhasePlaying && index >= 0 {
+		r.leaveGame(p, index)
+	}
+	r.updateCountdown()
 }
 
-func (r *Room) HandleLeave(message ClientLeave) {
+// endGame ends the game. Spectators take part in the next game.
+func (r *Room) endGame(reason string) {
+	r.GamePhase = GamePhaseEnd
+	r.CurrentTurn = 0
+	r.Paused = false
+	r.PausedAt = 0
+	r.autoPaused = false
+	r.promoteSpectators()
+	r.log.Info("game ended", "reason", reason)
+	r.broadcast(&serverPayload{
+		message: &ServerEnd{
+			Reason: reason,
+		},
+	})
+}
+
+// leaveGame updates the game after the player at index left.
+func (r *Room) leaveGame(p *Player, index int) {
+	if r.LeaveCards == LeaveCardsDrawPile {
+		r.returnCards(p.Hand)
+	}
+	p.Hand = PlayerHand{}
+
+	if len(r.Players) < 2 {
+		r.endGame("not_enough_players")
+		return
+	}
+
+	switch {
+	case index < r.CurrentTurn:
+		r.CurrentTurn--
+	case index == r.CurrentTurn:
+		// the next player takes the turn, and is now at the same index
+		r.CurrentTurn %= len(r.Players)
+		r.TurnStartedAt = time.Now().UnixMilli()
+		if r.autoPaused {
+			// paused because this player disconnected
+			r.resume("")
+		}
+		r.broadcast(&serverPayload{
+			message: &ServerTurn{
+				PlayerId: r.Players[r.CurrentTurn].Id,
+			},
+		})
+	}
+	r.resync()
+}
+
+func (r *Room) handleDisconnected(message playerDisconnected) {
 	p := message.Player
+	if r.getMember(p.Id) != p {
+		return
+	}
 
-	slices.Remove(r.Players, p)
+	p.Connected = false
+	p.disconnects++
+	disconnects := p.disconnects
+	if r.GamePhase == GamePhasePlaying && !r.Paused && r.Players[r.CurrentTurn] == p {
+		r.pause(p.Id, true)
+	}
+	time.AfterFunc(r.hub.config.Timeouts.Reconnect, func() {
+		r.post(playerTimedOut{p, disconnects})
+	})
 
-	r.outbound <- &serverPayload{
-		message: &ServerLeave{
-			Id: p.Id,
+	r.broadcast(&serverPayload{
+		exclude: set{p.Id: {}},
+		message: &ServerPresence{
+			Id:        p.Id,
+			Connected: false,
 		},
+	})
+}
+
+func (r *Room) handleReconnected(message playerReconnected) {
+	p := message.Player
+	if r.getMember(p.Id) != p {
+		return
+	}
+
+	p.Connected = true
+	if r.autoPaused && r.Players[r.CurrentTurn] == p {
+		r.resume("")
 	}
+	r.broadcast(&serverPayload{
+		exclude: set{p.Id: {}},
+		message: &ServerPresence{
+			Id:        p.Id,
+			Connected: true,
+		},
+	})
 }
 
-func (r *Room) HandleChangeDetails(message ClientChangeDetails) {
+func (r *Room) handleTimedOut(message playerTimedOut) {
+	p := message.Player
+	if r.getMember(p.Id) != p || p.Connected || p.disconnects != message.disconnects {
+		// reconnected in the meantime
+		return
+	}
+
+	r.HandleLeave(ClientLeave{Player: p})
+}
+
+func (r *Room) HandleChangeDetails(message ClientChangeDetails) error {
 	p := message.Player
 
 	if p.Id != r.OwnerId {
-		log.Println("[error] player is not owner")
-		p.outbound <- &ServerError{"player is not owner"}
-		return
+		return newError(ErrorNotOwner, "player is not owner")
 	}
 
 	if message.Name != nil {
@@ -111,6 +341,18 @@ func (r *Room) HandleChangeDetails(message ClientChangeDetails) {
 	if message.PlayMode != nil {
 		r.PlayMode = *message.PlayMode
 	}
+	if message.SlowMode != nil {
+		r.SlowMode = *message.SlowMode
+	}
+	if message.LeaveCards != nil {
+		r.LeaveCards = *message.LeaveCards
+	}
+	if message.LateJoin != nil {
+		r.LateJoin = *message.LateJoin
+	}
+	if message.AutoStart != nil {
+		r.AutoStart = *message.AutoStart
+	}
 	if len(message.AddDecks) > 0 {
 		toAdd := []*deck.Deck{}
 		for _, deckId := range message.AddDecks {
@@ -118,194 +360,639 @@ func (r *Room) HandleChangeDetails(message ClientChangeDetails) {
 				toAdd = append(toAdd, deck)
 			}
 		}
-		r.Decks = slices.Unique(append(r.Decks, toAdd...))
+		// compare by id, reloading the decks replaces the instances
+		r.Decks = slices.UniqueBy(append(r.Decks, toAdd...), func(d *deck.Deck) string { return d.Id })
 	}
 	if len(message.RemoveDecks) > 0 {
 		for _, deckId := range message.RemoveDecks {
-			if deck, ok := deck.Decks()[deckId]; ok {
-				r.Decks = slices.Remove(r.Decks, deck)
-			}
+			r.Decks = slices.Filter(r.Decks, func(d *deck.Deck) bool { return d.Id != deckId })
 		}
 	}
-
+	r.updateCountdown()
+	return nil
 }
 
-func (r *Room) HandleKick(message ClientKick) {
+func (r *Room) HandleKick(message ClientKick) error {
 	p := message.Player
 
 	if p.Id != r.OwnerId {
-		log.Println("[error] player is not owner")
-		p.outbound <- &ServerError{"player is not owner"}
-		return
+		return newError(ErrorNotOwner, "player is not owner")
+	}
+
+	if message.Id == p.Id {
+		return newError(ErrorKickSelf, "player cannot kick themselves")
+	}
+
+	target := r.getMember(message.Id)
+	if target == nil {
+		return newError(ErrorPlayerNotFound, "player not found").with("id", message.Id)
+	}
+
+	r.kick(target, message.Ban)
+	return nil
+}
+
+// kick removes a player from the room after telling them why. Banned players
+// cannot join again.
+func (r *Room) kick(p *Player, banned bool) {
+	if banned {
+		if r.bans == nil {
+			r.bans = set{}
+		}
+		for _, identity := range r.banIdentities(p) {
+			r.bans[identity] = struct{}{}
+		}
+		r.log.Info("player banned", "player", p.Id)
+	}
+	p.send(&ServerKick{
+		Banned: banned,
+	})
+	r.removePlayer(p, true)
+}
+
+// banIdentities returns the identities under which a banned player is
+// remembered: their session, which is kept when a dropped connection is
+// resumed, and their network address if bans by address are enabled. Only the
+// address also covers new connections.
+func (r *Room) banIdentities(p *Player) []string {
+	identities := []string{p.session}
+	if r.hub.config.Rooms.BanByAddress {
+		identities = append(identities, p.address)
+	}
+	return identities
+}
+
+func (r *Room) HandleVoteKick(message ClientVoteKick) error {
+	p := message.Player
+
+	if r.getPlayer(p.Id) != p {
+		return newError(ErrorSpectating, "spectators cannot vote")
+	}
+
+	if owner := r.getPlayer(r.OwnerId); owner != nil && owner.Connected {
+		return newError(ErrorOwnerPresent, "the owner can kick players")
 	}
 
 	if message.Id == p.Id {
-		log.Println("[error] player cannot kick themselves")
-		p.outbound <- &ServerError{"player cannot kick themselves"}
+		return newError(ErrorKickSelf, "player cannot kick themselves")
+	}
+
+	target := r.getPlayer(message.Id)
+	if target == nil {
+		return newError(ErrorPlayerNotFound, "player not found").with("id", message.Id)
+	}
+
+	if r.kickVotes == nil {
+		r.kickVotes = map[string]set{}
+	}
+	votes := r.kickVotes[target.Id]
+	if votes == nil {
+		votes = set{}
+		r.kickVotes[target.Id] = votes
+	}
+	votes[p.Id] = struct{}{}
+
+	// a majority of the connected players other than the target
+	voters := 0
+	for _, player := range r.Players {
+		if player != target && player.Connected {
+			voters++
+		}
+	}
+	required := voters/2 + 1
+
+	r.broadcast(&serverPayload{
+		message: &ServerVoteKick{
+			PlayerId: target.Id,
+			VoterId:  p.Id,
+			Votes:    len(votes),
+			Required: required,
+		},
+	})
+
+	if len(votes) >= required {
+		r.kick(target, false)
+	}
+	return nil
+}
+
+func (r *Room) HandleTransferOwnership(message ClientTransferOwnership) error {
+	p := message.Player
+
+	if p.Id != r.OwnerId {
+		return newError(ErrorNotOwner, "player is not owner")
+	}
+
+	target := r.getPlayer(message.Id)
+	if target == nil {
+		return newError(ErrorPlayerNotFound, "player not found").with("id", message.Id)
+	}
+
+	if target != p {
+		r.setOwner(target)
+	}
+	return nil
+}
+
+// setOwner makes p the owner of the room and notifies all players.
+func (r *Room) setOwner(p *Player) {
+	previous := r.OwnerId
+	r.OwnerId = p.Id
+	r.broadcast(&serverPayload{
+		message: &ServerOwnerChanged{
+			OwnerId:    p.Id,
+			PreviousId: previous,
+		},
+	})
+}
+
+// migrateOwner promotes the player who has been in the room the longest,
+// preferring connected players, after the owner left.
+func (r *Room) migrateOwner() {
+	if len(r.Players) == 0 {
+		r.OwnerId = ""
 		return
 	}
 
+	// the turn order differs from the join order once players join mid-game,
+	// so pick the connected player who joined first, or anyone who did
+	var next *Player
 	for _, player := range r.Players {
-		if player.Id == message.Id {
-			player.outbound <- &ServerKick{}
-			return
+		switch {
+		case next == nil,
+			player.Connected && !next.Connected,
+			player.Connected == next.Connected && player.joined < next.joined:
+			next = player
 		}
 	}
+	r.setOwner(next)
 }
 
-func (r *Room) HandleStart(message ClientStart) {
+const (
+	minPlayers        = config.MinPlayers // players needed to start a game
+	minCardsPerPlayer = 5                 // cards the selected decks need per player to start a game
+)
+
+func (r *Room) HandleReady(message ClientReady) error {
+	p := message.Player
+
+	if r.GamePhase == GamePhasePlaying {
+		return newError(ErrorGameStarted, "game has already started")
+	}
+
+	p.Ready = message.Ready
+	r.broadcast(&serverPayload{
+		message: &ServerReady{
+			PlayerId: p.Id,
+			Ready:    p.Ready,
+		},
+	})
+	r.updateCountdown()
+	return nil
+}
+
+func (r *Room) HandleStart(message ClientStart) error {
 	p := message.Player
 
 	if p.Id != r.OwnerId {
-		log.Println("[error] player is not owner")
-		p.outbound <- &ServerError{"player is not owner"}
+		return newError(ErrorNotOwner, "player is not owner")
+	}
+
+	if r.GamePhase == GamePhasePlaying {
+		return newError(ErrorGameStarted, "game has already started")
+	}
+
+	if err := r.checkStart(); err != nil {
+		return err
+	}
+
+	// the owner starting the game counts as being ready
+	if ids := r.notReady(false); len(ids) > 0 {
+		return newError(ErrorPlayersNotReady, "not all players are ready").with("ids", ids)
+	}
+
+	r.startGame()
+	return nil
+}
+
+// checkStart returns an error if the game cannot start with the current
+// players and decks.
+func (r *Room) checkStart() error {
+	if len(r.Players) < minPlayers {
+		return newError(ErrorNotEnoughPlayers, "not enough players").with("required", minPlayers)
+	}
+
+	if len(r.Decks) == 0 {
+		return newError(ErrorNoDecks, "no decks selected")
+	}
+
+	cards, wildCards := 0, 0
+	for _, d := range r.Decks {
+		cards += len(d.Cards)
+		wildCards += len(d.WildCards)
+	}
+	if wildCards == 0 {
+		return newError(ErrorNotEnoughCards, "selected decks have no wild cards")
+	}
+	if required := len(r.Players) * minCardsPerPlayer; cards < required {
+		return newError(ErrorNotEnoughCards, "not enough cards for the players").
+			with("required", required).
+			with("available", cards)
+	}
+	return nil
+}
+
+// notReady returns the ids of the players who are not ready.
+func (r *Room) notReady(includeOwner bool) []string {
+	ids := []string{}
+	for _, p := range r.Players {
+		if !p.Ready && (includeOwner || p.Id != r.OwnerId) {
+			ids = append(ids, p.Id)
+		}
+	}
+	return ids
+}
+
+// updateCountdown starts the auto-start countdown once every player is ready
+// and the game can start, and cancels it when that is no longer the case.
+func (r *Room) updateCountdown() {
+	running := r.AutoStart > 0 &&
+		r.GamePhase != GamePhasePlaying &&
+		len(r.notReady(true)) == 0 &&
+		r.checkStart() == nil
+	if running == r.counting {
 		return
 	}
 
-	if r.GamePhase != GamePhaseLobby {
-		log.Println("[error] game has already started")
-		p.outbound <- &ServerError{"game has already started"}
+	r.countdown++
+	r.counting = running
+	if !running {
+		r.broadcast(&serverPayload{
+			message: &ServerCountdown{},
+		})
 		return
 	}
 
+	countdown := r.countdown
+	delay := time.Duration(r.AutoStart) * time.Second
+	time.AfterFunc(delay, func() {
+		r.post(autoStart{countdown})
+	})
+	r.broadcast(&serverPayload{
+		message: &ServerCountdown{
+			Seconds:  r.AutoStart,
+			StartsAt: time.Now().Add(delay).UnixMilli(),
+		},
+	})
+}
+
+func (r *Room) handleAutoStart(message autoStart) {
+	if !r.counting || message.countdown != r.countdown {
+		// cancelled in the meantime
+		return
+	}
+
+	r.counting = false
+	if r.checkStart() == nil {
+		r.startGame()
+	}
+}
+
+// startGame deals a new game: it resets the players, prepares the draw pile
+// and the first wild card and picks the first player.
+func (r *Room) startGame() {
+	// stop any pending countdown
+	r.countdown++
+	r.counting = false
+
+	r.promoteSpectators()
+	for _, p := range r.Players {
+		p.Hand = PlayerHand{}
+		p.Score = 0
+		p.Ready = false
+	}
+
+	r.createDrawPile()
+	r.usedWildCards = []*card.WildCard{}
+	r.ActiveWildCard = r.takeWildCard()
+
 	r.GamePhase = GamePhasePlaying
+	r.GameStartedAt = time.Now().UnixMilli()
+	r.TurnStartedAt = r.GameStartedAt
 	// pick random player to start
 	r.CurrentTurn = rand.Intn(len(r.Players))
-	r.outbound <- &serverPayload{
+	r.log.Info("game started", "players", len(r.Players), "drawPile", r.DrawPileSize)
+	r.broadcast(&serverPayload{
 		message: &ServerStart{
 			CurrentTurn: r.CurrentTurn,
 		},
+	})
+}
+
+func (r *Room) HandlePause(message ClientPause) error {
+	p := message.Player
+
+	if p.Id != r.OwnerId {
+		return newError(ErrorNotOwner, "player is not owner")
+	}
+
+	if r.GamePhase != GamePhasePlaying {
+		return newError(ErrorGameNotPlaying, "game is not in playing phase")
+	}
+
+	if r.Paused {
+		return newError(ErrorGamePaused, "game is already paused")
+	}
+
+	r.pause(p.Id, false)
+	return nil
+}
+
+func (r *Room) HandleResume(message ClientResume) error {
+	p := message.Player
+
+	if p.Id != r.OwnerId {
+		return newError(ErrorNotOwner, "player is not owner")
+	}
+
+	if !r.Paused {
+		return newError(ErrorGameNotPaused, "game is not paused")
 	}
+
+	r.resume(p.Id)
+	return nil
 }
 
-func (r *Room) HandleDraw(message ClientDraw) {
+// pause pauses the game. playerId is the player who paused it, or the
+// disconnected current player if automatic.
+func (r *Room) pause(playerId string, automatic bool) {
+	r.Paused = true
+	r.PausedAt = time.Now().UnixMilli()
+	r.autoPaused = automatic
+	r.broadcast(&serverPayload{
+		message: &ServerPause{
+			PlayerId:  playerId,
+			Automatic: automatic,
+		},
+	})
+}
+
+// resume resumes the game. The clocks are moved forward by the length of the
+// pause, so paused time does not count. playerId is empty if automatic.
+func (r *Room) resume(playerId string) {
+	duration := time.Now().UnixMilli() - r.PausedAt
+	r.GameStartedAt += duration
+	r.TurnStartedAt += duration
+
+	r.Paused = false
+	r.PausedAt = 0
+	r.autoPaused = false
+	r.broadcast(&serverPayload{
+		message: &ServerResume{
+			PlayerId: playerId,
+			Duration: duration,
+		},
+	})
+}
+
+func (r *Room) HandleDraw(message ClientDraw) error {
 	p := message.Player
 
 	if r.GamePhase != GamePhasePlaying {
-		log.Println("[error] game is not in playing phase")
-		p.outbound <- &ServerError{"game is not in playing phase"}
-		return
+		return newError(ErrorGameNotPlaying, "game is not in playing phase")
+	}
+
+	if r.Paused {
+		return newError(ErrorGamePaused, "game is paused")
+	}
+
+	if r.getPlayer(p.Id) == nil {
+		return newError(ErrorSpectating, "spectators cannot play")
 	}
 
 	if p.Id != r.Players[r.CurrentTurn].Id {
-		log.Println("[error] player is not current turn")
-		p.outbound <- &ServerError{"player is not current turn"}
-		return
+		return newError(ErrorNotYourTurn, "player is not current turn")
 	}
 
 	c, err := r.drawCard()
 	if err != nil {
 		// error occurs when there are no cards left
 		// should never happen as we replenish the deck after each draw
-		log.Println("[error]", err)
-		p.outbound <- &ServerError{err.Error()}
-		return
+		return newError(ErrorDrawPileEmpty, err.Error())
 	}
 
 	if wild, ok := c.(*card.WildCard); ok {
 		r.usedWildCards = append(r.usedWildCards, r.ActiveWildCard)
 		r.ActiveWildCard = wild
-		r.outbound <- &serverPayload{
+		r.broadcast(&serverPayload{
 			message: &ServerWildCard{
 				PlayerId: p.Id,
 				Card:     wild,
 			},
-		}
+		})
 	} else {
-		r.outbound <- &serverPayload{
+		p.Hand = append(p.Hand, c.(*card.Card))
+		r.broadcast(&serverPayload{
 			message: &ServerDraw{
 				PlayerId: p.Id,
 				Card:     c.(*card.Card),
 			},
-		}
+		})
 
 		r.CurrentTurn = (r.CurrentTurn + 1) % len(r.Players)
+		r.TurnStartedAt = time.Now().UnixMilli()
 		r.resync()
-		r.outbound <- &serverPayload{
+		r.broadcast(&serverPayload{
 			message: &ServerTurn{
 				PlayerId: r.Players[r.CurrentTurn].Id,
 			},
-		}
+		})
 	}
 
 	if r.DrawPileSize == 0 {
 		// reshuffle
 		r.recreateDrawPile()
 		for _, player := range r.Players {
-			player.outbound <- &ServerReshuffle{
+			r.send(player, &ServerReshuffle{
 				Player: player,
-			}
+			})
 		}
 	}
+	return nil
 }
 
-func (r *Room) HandleSend(message ClientSend) {
+func (r *Room) HandleSend(message ClientSend) error {
 	p := message.Player
 
 	if r.GamePhase != GamePhasePlaying {
-		log.Println("[error] game is not in playing phase")
-		p.outbound <- &ServerError{"game is not in playing phase"}
+		return newError(ErrorGameNotPlaying, "game is not in playing phase")
+	}
+
+	if r.Paused {
+		return newError(ErrorGamePaused, "game is paused")
+	}
+
+	if r.getPlayer(p.Id) == nil {
+		return newError(ErrorSpectating, "spectators cannot play")
 	}
 
 	target := r.getPlayer(message.RecipientId)
 	if target == nil {
-		log.Println("[error] target player not found")
-		p.outbound <- &ServerError{"target player not found"}
+		return newError(ErrorPlayerNotFound, "target player not found")
 	}
 
 	senderTop := p.Hand.top()
 	targetTop := target.Hand.top()
 
-	if !senderTop.CompatibleWith(targetTop, r.ActiveWildCard) {
-		log.Println("[error] cards are not compatible")
-		p.outbound <- &ServerError{"cards are not compatible"}
+	if senderTop == nil || targetTop == nil || !senderTop.CompatibleWith(targetTop, r.ActiveWildCard) {
+		return newError(ErrorIncompatibleCards, "cards are not compatible")
 	}
 
 	p.Hand = p.Hand.tail()
 	target.Hand = append(target.Hand, senderTop)
 	target.Score++
 
-	p.outbound <- &ServerSend{
-		SenderId:    p.Id,
-		RecipientId: target.Id,
-		Card:        senderTop,
-	}
-
-	target.outbound <- &ServerSend{
-		SenderId:    p.Id,
-		RecipientId: target.Id,
-		Card:        senderTop,
-	}
+	r.broadcast(&serverPayload{
+		include: set{p.Id: {}, target.Id: {}},
+		message: &ServerSend{
+			SenderId:    p.Id,
+			RecipientId: target.Id,
+			Card:        senderTop,
+		},
+	})
 
 	r.resync()
+	return nil
 }
 
-func (r *Room) HandleChat(message ClientChat) {
+// chatHistorySize is the number of public chat messages kept per room.
+const chatHistorySize = 50
+
+func (r *Room) HandleChat(message ClientChat) error {
+	p := message.Player
 	if message.Message == "" {
-		return
+		return nil
+	}
+
+	// commands count as chat messages, so muted players cannot use them to
+	// talk and slow mode limits them too
+	now := time.Now()
+	if p.MutedUntil > now.UnixMilli() {
+		return newError(ErrorMuted, "You are muted").with("until", p.MutedUntil)
+	}
+	if r.SlowMode > 0 && p.Id != r.OwnerId && !p.lastChat.IsZero() {
+		wait := time.Duration(r.SlowMode)*time.Second - now.Sub(p.lastChat)
+		if wait > 0 {
+			return newError(ErrorSlowMode, "Slow mode is enabled").with("retryAfter", wait.Milliseconds())
+		}
+	}
+
+	text := message.Message
+	if message.RecipientId == nil && strings.HasPrefix(text, "/") {
+		if !strings.HasPrefix(text, "//") {
+			if err := r.runCommand(message); err != nil {
+				return err
+			}
+			p.lastChat = now
+			return nil
+		}
+		// "//" escapes a message starting with a slash
+		text = text[1:]
 	}
 
+	var recipient *Player
 	if message.RecipientId != nil {
-		recipient := r.getPlayer(*message.RecipientId)
+		recipient = r.getMember(*message.RecipientId)
 		if recipient == nil {
-			message.Player.outbound <- &ServerError{"player not found"}
-			return
+			return newError(ErrorPlayerNotFound, "player not found")
 		}
-		recipient.outbound <- &ServerChat{
-			Timestamp: fmt.Sprint(time.Now().UnixMilli()),
-			PlayerId:  message.Player.Id,
-			Message:   message.Message,
-			Private:   true,
+	}
+
+	p.lastChat = now
+	chat := r.newChat(p.Id, ChatFilter.Filter(text))
+	if recipient != nil {
+		chat.Private = true
+		recipient.send(chat)
+		return nil
+	}
+
+	r.broadcastChat(chat)
+	return nil
+}
+
+// newChat returns a chat message with a new id. playerId is empty for system
+// messages.
+func (r *Room) newChat(playerId string, text string) *ServerChat {
+	r.chatCounter++
+	return &ServerChat{
+		Id:        fmt.Sprintf("%s_%d", r.Id, r.chatCounter),
+		Timestamp: fmt.Sprint(time.Now().UnixMilli()),
+		PlayerId:  playerId,
+		System:    playerId == "",
+		Message:   text,
+	}
+}
+
+// broadcastChat sends a public chat message to all players and keeps it in
+// the chat history.
+func (r *Room) broadcastChat(chat *ServerChat) {
+	r.chatHistory = append(r.chatHistory, chat)
+	if len(r.chatHistory) > chatHistorySize {
+		r.chatHistory = r.chatHistory[len(r.chatHistory)-chatHistorySize:]
+	}
+	r.broadcast(&serverPayload{
+		message: chat,
+	})
+}
+
+func (r *Room) HandleDeleteChat(message ClientDeleteChat) error {
+	p := message.Player
+
+	if p.Id != r.OwnerId {
+		return newError(ErrorNotOwner, "player is not owner")
+	}
+
+	for i, chat := range r.chatHistory {
+		if chat.Id == message.Id {
+			r.chatHistory = append(r.chatHistory[:i:i], r.chatHistory[i+1:]...)
+			r.broadcast(&serverPayload{
+				message: &ServerChatDeleted{
+					Id: message.Id,
+				},
+			})
+			return nil
 		}
-		return
 	}
+	return newError(ErrorMessageNotFound, "message not found").with("id", message.Id)
+}
 
-	r.outbound <- &serverPayload{
-		message: &ServerChat{
-			Timestamp: fmt.Sprint(time.Now().UnixMilli()),
-			PlayerId:  message.Player.Id,
-			Message:   message.Message,
-			Private:   false,
-		},
+func (r *Room) HandleMute(message ClientMute) error {
+	p := message.Player
+
+	if p.Id != r.OwnerId {
+		return newError(ErrorNotOwner, "player is not owner")
+	}
+
+	target := r.getMember(message.Id)
+	if target == nil {
+		return newError(ErrorPlayerNotFound, "player not found").with("id", message.Id)
+	}
+
+	target.MutedUntil = 0
+	if message.Duration > 0 {
+		target.MutedUntil = time.Now().Add(time.Duration(message.Duration) * time.Second).UnixMilli()
 	}
+
+	r.broadcast(&serverPayload{
+		message: &ServerMute{
+			PlayerId: target.Id,
+			Until:    target.MutedUntil,
+		},
+	})
+	return nil
+}
+
+func (r *Room) HandleRequestSnapshot(message ClientRequestSnapshot) error {
+	r.send(message.Player, &ServerSnapshot{})
+	return nil
 }
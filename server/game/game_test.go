@@ -0,0 +1,630 @@
+package game
+
+import (
+	"cardgame/card"
+	"cardgame/deck"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestRoom returns a room with the given players, the first one being the
+// owner. The room has no read goroutine, so tests call its handlers directly.
+// It is closed when the test ends.
+func newTestRoom(t *testing.T, players ...*Player) *Room {
+	t.Helper()
+	r := HubMain.newRoom("")
+	t.Cleanup(func() { HubMain.CloseRoom(r.Id, "test ended") })
+
+	r.Players = players
+	for _, p := range players {
+		p.room = r
+		r.joinCounter++
+		p.joined = r.joinCounter
+	}
+	if len(players) > 0 {
+		r.OwnerId = players[0].Id
+	}
+	return r
+}
+
+// newTestDeck returns a deck with the given number of cards and wild cards.
+func newTestDeck(cards, wildCards int) *deck.Deck {
+	d := &deck.Deck{Id: "d_test", Name: "Test"}
+	for i := 0; i < cards; i++ {
+		d.Cards = append(d.Cards, &card.Card{Id: fmt.Sprintf("c_%d", i)})
+	}
+	for i := 0; i < wildCards; i++ {
+		d.WildCards = append(d.WildCards, &card.WildCard{Id: fmt.Sprintf("w_%d", i)})
+	}
+	return d
+}
+
+// receive waits for the next message of type T sent to the player, skipping
+// any other messages.
+func receive[T ServerMessage](t *testing.T, p *Player) T {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case <-p.outbound.ready:
+			for _, m := range p.outbound.drain() {
+				if m, ok := m.message.(T); ok {
+					return m
+				}
+			}
+		case <-timeout:
+			var zero T
+			t.Fatalf("no %T received", zero)
+			return zero
+		}
+	}
+}
+
+func TestChatHistory(t *testing.T) {
+	owner := newTestPlayer("p_owner")
+	r := newTestRoom(t, owner)
+
+	for i := 0; i < chatHistorySize+5; i++ {
+		assert.NoError(t, r.HandleChat(ClientChat{Player: owner, Message: "hello"}))
+	}
+	assert.NoError(t, r.HandleChat(ClientChat{Player: owner, Message: "psst", RecipientId: &owner.Id}))
+
+	p := newTestPlayer("p_joiner")
+	assert.NoError(t, r.HandleJoin(ClientJoin{Player: p, Name: "  joiner  "}))
+	assert.Equal(t, "joiner", p.Name)
+
+	history := receive[*ServerChatHistory](t, p)
+	assert.Len(t, history.Messages, chatHistorySize, "history is bounded")
+	for _, m := range history.Messages {
+		assert.False(t, m.Private, "private messages are not kept")
+	}
+	assert.Equal(t, r.chatHistory[len(r.chatHistory)-1].Id, history.Messages[chatHistorySize-1].Id)
+}
+
+func TestDeleteChat(t *testing.T) {
+	owner := newTestPlayer("p_owner")
+	other := newTestPlayer("p_other")
+	r := newTestRoom(t, owner, other)
+
+	assert.NoError(t, r.HandleChat(ClientChat{Player: other, Message: "spam"}))
+	chat := receive[*ServerChat](t, owner)
+
+	err := r.HandleDeleteChat(ClientDeleteChat{Player: other, Id: chat.Id})
+	assert.Equal(t, ErrorNotOwner, err.(*Error).Code)
+
+	assert.NoError(t, r.HandleDeleteChat(ClientDeleteChat{Player: owner, Id: chat.Id}))
+	assert.Equal(t, chat.Id, receive[*ServerChatDeleted](t, other).Id)
+	assert.Empty(t, r.chatHistory)
+
+	err = r.HandleDeleteChat(ClientDeleteChat{Player: owner, Id: chat.Id})
+	assert.Equal(t, ErrorMessageNotFound, err.(*Error).Code)
+}
+
+func TestMute(t *testing.T) {
+	owner := newTestPlayer("p_owner")
+	other := newTestPlayer("p_other")
+	r := newTestRoom(t, owner, other)
+
+	err := r.HandleMute(ClientMute{Player: other, Id: owner.Id, Duration: 60})
+	assert.Equal(t, ErrorNotOwner, err.(*Error).Code)
+
+	assert.NoError(t, r.HandleMute(ClientMute{Player: owner, Id: other.Id, Duration: 60}))
+	mute := receive[*ServerMute](t, other)
+	assert.Equal(t, other.Id, mute.PlayerId)
+	assert.Greater(t, mute.Until, time.Now().UnixMilli())
+
+	err = r.HandleChat(ClientChat{Player: other, Message: "hello"})
+	assert.Equal(t, ErrorMuted, err.(*Error).Code)
+
+	assert.NoError(t, r.HandleMute(ClientMute{Player: owner, Id: other.Id, Duration: 0}))
+	assert.Zero(t, receive[*ServerMute](t, other).Until)
+	assert.NoError(t, r.HandleChat(ClientChat{Player: other, Message: "hello"}))
+}
+
+func TestSlowMode(t *testing.T) {
+	owner := newTestPlayer("p_owner")
+	other := newTestPlayer("p_other")
+	r := newTestRoom(t, owner, other)
+	r.SlowMode = 10
+
+	assert.NoError(t, r.HandleChat(ClientChat{Player: other, Message: "one"}))
+	err := r.HandleChat(ClientChat{Player: other, Message: "two"})
+	assert.Equal(t, ErrorSlowMode, err.(*Error).Code)
+	assert.Contains(t, err.(*Error).Details, "retryAfter")
+
+	other.lastChat = time.Now().Add(-10 * time.Second)
+	assert.NoError(t, r.HandleChat(ClientChat{Player: other, Message: "three"}))
+
+	// the owner is not limited
+	assert.NoError(t, r.HandleChat(ClientChat{Player: owner, Message: "one"}))
+	assert.NoError(t, r.HandleChat(ClientChat{Player: owner, Message: "two"}))
+}
+
+func TestChatFilter(t *testing.T) {
+	defer func(f *WordFilter) { ChatFilter = f }(ChatFilter)
+	ChatFilter = NewWordFilter([]string{"darn"})
+
+	owner := newTestPlayer("p_owner")
+	r := newTestRoom(t, owner)
+
+	assert.NoError(t, r.HandleChat(ClientChat{Player: owner, Message: "darn it"}))
+	assert.Equal(t, "**** it", receive[*ServerChat](t, owner).Message)
+
+	p := newTestPlayer("p_joiner")
+	assert.NoError(t, r.HandleJoin(ClientJoin{Player: p, Name: "Darn"}))
+	assert.Equal(t, "****", p.Name)
+}
+
+func TestChangeDecksAfterReload(t *testing.T) {
+	dir := t.TempDir()
+	yml := "name: Reloaded\ncards:\n  - \"=|One\"\n  - \"≈|Two\"\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "reloaded.yml"), []byte(yml), 0644))
+	var id string
+	for id = range deck.InitDecks(dir) {
+		if deck.Decks()[id].Name == "Reloaded" {
+			break
+		}
+	}
+
+	owner := newTestPlayer("p_owner")
+	r := newTestRoom(t, owner)
+	assert.NoError(t, r.HandleChangeDetails(ClientChangeDetails{Player: owner, AddDecks: []string{id}}))
+
+	_, err := deck.ReloadDecks()
+	assert.NoError(t, err)
+	assert.NotSame(t, r.Decks[0], deck.Decks()[id], "reloading replaces the decks")
+
+	assert.NoError(t, r.HandleChangeDetails(ClientChangeDetails{Player: owner, AddDecks: []string{id}}))
+	assert.Len(t, r.Decks, 1, "a reloaded deck is not added twice")
+
+	assert.NoError(t, r.HandleChangeDetails(ClientChangeDetails{Player: owner, RemoveDecks: []string{id}}))
+	assert.Empty(t, r.Decks, "a deck selected before reloading can be removed")
+}
+
+func TestTransferOwnership(t *testing.T) {
+	owner := newTestPlayer("p_owner")
+	other := newTestPlayer("p_other")
+	r := newTestRoom(t, owner, other)
+
+	err := r.HandleTransferOwnership(ClientTransferOwnership{Player: other, Id: other.Id})
+	assert.Equal(t, ErrorNotOwner, err.(*Error).Code)
+	err = r.HandleTransferOwnership(ClientTransferOwnership{Player: owner, Id: "p_missing"})
+	assert.Equal(t, ErrorPlayerNotFound, err.(*Error).Code)
+
+	assert.NoError(t, r.HandleTransferOwnership(ClientTransferOwnership{Player: owner, Id: other.Id}))
+	assert.Equal(t, other.Id, r.OwnerId)
+	changed := receive[*ServerOwnerChanged](t, owner)
+	assert.Equal(t, other.Id, changed.OwnerId)
+	assert.Equal(t, owner.Id, changed.PreviousId)
+}
+
+func TestOwnerMigration(t *testing.T) {
+	owner := newTestPlayer("p_owner")
+	away := newTestPlayer("p_away")
+	away.Connected = false
+	next := newTestPlayer("p_next")
+	last := newTestPlayer("p_last")
+	r := newTestRoom(t, owner, away, next, last)
+
+	assert.NoError(t, r.HandleLeave(ClientLeave{Player: last}))
+	assert.Equal(t, owner.Id, r.OwnerId, "owner stays when another player leaves")
+
+	assert.NoError(t, r.HandleLeave(ClientLeave{Player: owner}))
+	assert.Equal(t, next.Id, r.OwnerId, "longest present connected player is promoted")
+	assert.Equal(t, next.Id, receive[*ServerOwnerChanged](t, next).OwnerId)
+
+	assert.NoError(t, r.HandleLeave(ClientLeave{Player: next}))
+	assert.Equal(t, away.Id, r.OwnerId, "disconnected players are promoted if nobody else is left")
+
+	assert.NoError(t, r.HandleLeave(ClientLeave{Player: away}))
+	assert.Empty(t, r.OwnerId)
+}
+
+func TestKick(t *testing.T) {
+	owner := newTestPlayer("p_owner")
+	other := newTestPlayer("p_other")
+	r := newTestRoom(t, owner, other)
+
+	err := r.HandleKick(ClientKick{Player: other, Id: owner.Id})
+	assert.Equal(t, ErrorNotOwner, err.(*Error).Code)
+	err = r.HandleKick(ClientKick{Player: owner, Id: owner.Id})
+	assert.Equal(t, ErrorKickSelf, err.(*Error).Code)
+
+	assert.NoError(t, r.HandleKick(ClientKick{Player: owner, Id: other.Id}))
+	assert.False(t, receive[*ServerKick](t, other).Banned)
+	assert.Nil(t, r.getPlayer(other.Id), "kicked player is removed")
+	assert.Nil(t, other.room)
+	assert.True(t, receive[*ServerLeave](t, owner).Kicked)
+
+	// kicked players may rejoin
+	assert.NoError(t, r.HandleJoin(ClientJoin{Player: other}))
+}
+
+func TestBan(t *testing.T) {
+	owner := newTestPlayer("p_owner")
+	other := newTestPlayer("p_other")
+	r := newTestRoom(t, owner, other)
+
+	assert.NoError(t, r.HandleKick(ClientKick{Player: owner, Id: other.Id, Ban: true}))
+	assert.True(t, receive[*ServerKick](t, other).Banned)
+	assert.Nil(t, r.getPlayer(other.Id))
+
+	err := r.HandleJoin(ClientJoin{Player: other})
+	assert.Equal(t, ErrorBanned, err.(*Error).Code)
+
+	// a new connection from the same address has a new session, but is
+	// banned by address
+	again := newTestPlayer("p_again")
+	again.address = other.address
+	err = r.HandleJoin(ClientJoin{Player: again})
+	assert.Equal(t, ErrorBanned, err.(*Error).Code)
+
+	// without bans by address, only the session is banned
+	r.hub.config.Rooms.BanByAddress = false
+	defer func() { r.hub.config.Rooms.BanByAddress = true }()
+	neighbor := newTestPlayer("p_neighbor")
+	neighbor.address = other.address
+	assert.NoError(t, r.HandleJoin(ClientJoin{Player: neighbor}))
+	assert.NoError(t, r.HandleKick(ClientKick{Player: owner, Id: neighbor.Id, Ban: true}))
+	neighbor2 := newTestPlayer("p_neighbor2")
+	neighbor2.address = other.address
+	assert.NoError(t, r.HandleJoin(ClientJoin{Player: neighbor2}))
+
+	assert.NoError(t, r.HandleJoin(ClientJoin{Player: newTestPlayer("p_new")}))
+}
+
+func TestVoteKick(t *testing.T) {
+	owner := newTestPlayer("p_owner")
+	a := newTestPlayer("p_a")
+	b := newTestPlayer("p_b")
+	c := newTestPlayer("p_c")
+	target := newTestPlayer("p_target")
+	r := newTestRoom(t, owner, a, b, c, target)
+
+	err := r.HandleVoteKick(ClientVoteKick{Player: a, Id: target.Id})
+	assert.Equal(t, ErrorOwnerPresent, err.(*Error).Code)
+
+	owner.Connected = false
+	err = r.HandleVoteKick(ClientVoteKick{Player: a, Id: a.Id})
+	assert.Equal(t, ErrorKickSelf, err.(*Error).Code)
+
+	spectator := newTestPlayer("p_spectator")
+	r.Spectators = []*Player{spectator}
+	err = r.HandleVoteKick(ClientVoteKick{Player: spectator, Id: target.Id})
+	assert.Equal(t, ErrorSpectating, err.(*Error).Code)
+
+	// 3 connected voters, so 2 votes are needed
+	assert.NoError(t, r.HandleVoteKick(ClientVoteKick{Player: a, Id: target.Id}))
+	assert.NoError(t, r.HandleVoteKick(ClientVoteKick{Player: a, Id: target.Id}))
+	vote := receive[*ServerVoteKick](t, b)
+	assert.Equal(t, 1, vote.Votes)
+	assert.Equal(t, 2, vote.Required)
+	assert.NotNil(t, r.getPlayer(target.Id), "repeated votes count once")
+
+	assert.NoError(t, r.HandleVoteKick(ClientVoteKick{Player: b, Id: target.Id}))
+	assert.Nil(t, r.getPlayer(target.Id))
+	assert.False(t, receive[*ServerKick](t, target).Banned)
+}
+
+func TestLeaveOutsideRoom(t *testing.T) {
+	p := newTestPlayer("p_alone")
+	HubMain.handleLeave(ClientLeave{Player: p, RequestId: "req-1"})
+	e := receive[*ServerError](t, p)
+	assert.Equal(t, ErrorNotInRoom, e.Code)
+	assert.Equal(t, "req-1", e.RequestId)
+
+	// a leave that reaches the room after the player was removed
+	owner := newTestPlayer("p_owner")
+	r := newTestRoom(t, owner)
+	r.HandleMessage(ClientLeave{Player: p, RequestId: "req-2"})
+	e = receive[*ServerError](t, p)
+	assert.Equal(t, ErrorNotInRoom, e.Code)
+	assert.Equal(t, "req-2", e.RequestId)
+}
+
+func TestLeaveMidGame(t *testing.T) {
+	newGame := func(t *testing.T, current int) (*Room, []*Player) {
+		players := []*Player{newTestPlayer("p_0"), newTestPlayer("p_1"), newTestPlayer("p_2"), newTestPlayer("p_3")}
+		for _, p := range players {
+			p.Hand = PlayerHand{{Id: p.Id + "_a"}, {Id: p.Id + "_b"}}
+		}
+		r := newTestRoom(t, players...)
+		r.GamePhase = GamePhasePlaying
+		r.CurrentTurn = current
+		return r, players
+	}
+
+	t.Run("before current player", func(t *testing.T) {
+		r, players := newGame(t, 2)
+		assert.NoError(t, r.HandleLeave(ClientLeave{Player: players[0]}))
+		assert.Len(t, r.Players, 3)
+		assert.Equal(t, players[2], r.Players[r.CurrentTurn])
+	})
+
+	t.Run("after current player", func(t *testing.T) {
+		r, players := newGame(t, 1)
+		assert.NoError(t, r.HandleLeave(ClientLeave{Player: players[3]}))
+		assert.Equal(t, players[1], r.Players[r.CurrentTurn])
+	})
+
+	t.Run("current player", func(t *testing.T) {
+		r, players := newGame(t, 1)
+		assert.NoError(t, r.HandleLeave(ClientLeave{Player: players[1]}))
+		assert.Equal(t, players[2], r.Players[r.CurrentTurn])
+		assert.Equal(t, players[2].Id, receive[*ServerTurn](t, players[0]).PlayerId)
+	})
+
+	t.Run("current player at end of turn order", func(t *testing.T) {
+		r, players := newGame(t, 3)
+		assert.NoError(t, r.HandleLeave(ClientLeave{Player: players[3]}))
+		assert.Equal(t, players[0], r.Players[r.CurrentTurn])
+	})
+
+	t.Run("cards return to draw pile", func(t *testing.T) {
+		r, players := newGame(t, 0)
+		assert.NoError(t, r.HandleLeave(ClientLeave{Player: players[1]}))
+		assert.Equal(t, 2, r.DrawPileSize)
+		assert.Len(t, r.drawPile, 2)
+		assert.Empty(t, players[1].Hand)
+	})
+
+	t.Run("cards discarded", func(t *testing.T) {
+		r, players := newGame(t, 0)
+		r.LeaveCards = LeaveCardsDiscard
+		assert.NoError(t, r.HandleLeave(ClientLeave{Player: players[1]}))
+		assert.Zero(t, r.DrawPileSize)
+		assert.Empty(t, players[1].Hand)
+	})
+
+	t.Run("game ends with one player left", func(t *testing.T) {
+		r, players := newGame(t, 0)
+		assert.NoError(t, r.HandleLeave(ClientLeave{Player: players[0]}))
+		assert.NoError(t, r.HandleLeave(ClientLeave{Player: players[1]}))
+		assert.Equal(t, GamePhasePlaying, r.GamePhase)
+		assert.NoError(t, r.HandleLeave(ClientLeave{Player: players[2]}))
+		assert.Equal(t, GamePhaseEnd, r.GamePhase)
+		assert.Equal(t, "not_enough_players", receive[*ServerEnd](t, players[3]).Reason)
+	})
+}
+
+func TestJoinMidGame(t *testing.T) {
+	newGame := func(t *testing.T, lateJoin bool) (*Room, []*Player) {
+		players := []*Player{newTestPlayer("p_0"), newTestPlayer("p_1"), newTestPlayer("p_2")}
+		r := newTestRoom(t, players...)
+		r.GamePhase = GamePhasePlaying
+		r.CurrentTurn = 1
+		r.LateJoin = lateJoin
+		return r, players
+	}
+
+	t.Run("late join", func(t *testing.T) {
+		r, players := newGame(t, true)
+		p := newTestPlayer("p_new")
+		assert.NoError(t, r.HandleJoin(ClientJoin{Player: p}))
+		receive[*ServerSnapshot](t, p)
+		assert.False(t, receive[*ServerJoin](t, players[0]).Spectator)
+
+		assert.Equal(t, []*Player{players[0], p, players[1], players[2]}, r.Players)
+		assert.Equal(t, players[1], r.Players[r.CurrentTurn], "current player keeps the turn")
+	})
+
+	t.Run("owner migration", func(t *testing.T) {
+		r, players := newGame(t, true)
+		p := newTestPlayer("p_new")
+		assert.NoError(t, r.HandleJoin(ClientJoin{Player: p}))
+
+		assert.NoError(t, r.HandleLeave(ClientLeave{Player: players[0]}))
+		assert.Equal(t, players[1].Id, r.OwnerId, "the late joiner's seat does not make them the oldest player")
+	})
+
+	t.Run("spectate", func(t *testing.T) {
+		r, players := newGame(t, false)
+		p := newTestPlayer("p_new")
+		assert.NoError(t, r.HandleJoin(ClientJoin{Player: p}))
+		receive[*ServerSnapshot](t, p)
+		assert.True(t, receive[*ServerJoin](t, players[0]).Spectator)
+		assert.Equal(t, []*Player{p}, r.Spectators)
+		assert.Len(t, r.Players, 3)
+
+		err := r.HandleDraw(ClientDraw{Player: p})
+		assert.Equal(t, ErrorSpectating, err.(*Error).Code)
+		err = r.HandleSend(ClientSend{Player: p, RecipientId: players[0].Id})
+		assert.Equal(t, ErrorSpectating, err.(*Error).Code)
+
+		// spectators play in the next game
+		assert.NoError(t, r.HandleLeave(ClientLeave{Player: players[0]}))
+		assert.NoError(t, r.HandleLeave(ClientLeave{Player: players[1]}))
+		assert.Equal(t, GamePhaseEnd, r.GamePhase)
+		assert.Empty(t, r.Spectators)
+		assert.Equal(t, []*Player{players[2], p}, r.Players)
+	})
+
+	t.Run("spectate when full", func(t *testing.T) {
+		r, _ := newGame(t, true)
+		r.MaxPlayers = 3
+		p := newTestPlayer("p_new")
+		assert.NoError(t, r.HandleJoin(ClientJoin{Player: p}))
+		assert.Equal(t, []*Player{p}, r.Spectators)
+
+		assert.NoError(t, r.HandleLeave(ClientLeave{Player: p}))
+		assert.Empty(t, r.Spectators)
+	})
+}
+
+func TestStart(t *testing.T) {
+	owner := newTestPlayer("p_owner")
+	other := newTestPlayer("p_other")
+	r := newTestRoom(t, owner)
+
+	codeOf := func(err error) ErrorCode {
+		if err == nil {
+			return ""
+		}
+		return err.(*Error).Code
+	}
+	start := func() error { return r.HandleStart(ClientStart{Player: owner}) }
+
+	assert.Equal(t, ErrorNotEnoughPlayers, codeOf(start()))
+	assert.NoError(t, r.HandleJoin(ClientJoin{Player: other}))
+	assert.Equal(t, ErrorNoDecks, codeOf(start()))
+	r.Decks = append(r.Decks, newTestDeck(20, 0))
+	assert.Equal(t, ErrorNotEnoughCards, codeOf(start()), "no wild cards")
+	r.Decks = append(r.Decks, newTestDeck(0, 3))
+	r.MaxPlayers = 8
+	for i := 0; i < 3; i++ {
+		r.Players = append(r.Players, newTestPlayer(fmt.Sprint("p_", i)))
+	}
+	err := start()
+	assert.Equal(t, ErrorNotEnoughCards, codeOf(err))
+	assert.Equal(t, 5*minCardsPerPlayer, err.(*Error).Details["required"])
+	r.Players = r.Players[:2]
+
+	err = start()
+	assert.Equal(t, ErrorPlayersNotReady, codeOf(err))
+	assert.Equal(t, []string{other.Id}, err.(*Error).Details["ids"])
+
+	assert.NoError(t, r.HandleReady(ClientReady{Player: other, Ready: true}))
+	assert.True(t, receive[*ServerReady](t, owner).Ready)
+	assert.NoError(t, start())
+
+	assert.Equal(t, GamePhasePlaying, r.GamePhase)
+	assert.NotNil(t, r.ActiveWildCard, "first wild card is prepared")
+	assert.Equal(t, 22, r.DrawPileSize)
+	assert.False(t, other.Ready, "ready state is reset for the next game")
+	assert.Equal(t, ErrorGameStarted, codeOf(start()))
+
+	// the current player draws into their hand until they draw a card
+	current := r.Players[r.CurrentTurn]
+	for current == r.Players[r.CurrentTurn] {
+		assert.NoError(t, r.HandleDraw(ClientDraw{Player: current}))
+	}
+	assert.Len(t, current.Hand, 1)
+}
+
+func TestReshuffle(t *testing.T) {
+	owner := newTestPlayer("p_owner")
+	other := newTestPlayer("p_other")
+	r := newTestRoom(t, owner, other)
+	r.Decks = append(r.Decks, newTestDeck(6, 2))
+	r.startGame()
+
+	reshuffles := 0
+	for i := 0; i < 100 && reshuffles < 2; i++ {
+		size := r.DrawPileSize
+		current := r.Players[r.CurrentTurn]
+		assert.NoError(t, r.HandleDraw(ClientDraw{Player: current}))
+		if r.DrawPileSize > size {
+			reshuffles++
+		}
+		if !assert.Equal(t, len(r.drawPile), r.DrawPileSize) {
+			break
+		}
+	}
+	assert.Equal(t, 2, reshuffles)
+	assert.Positive(t, r.DrawPileSize)
+}
+
+func TestAutoStart(t *testing.T) {
+	owner := newTestPlayer("p_owner")
+	other := newTestPlayer("p_other")
+	r := newTestRoom(t, owner, other)
+	r.Decks = append(r.Decks, newTestDeck(10, 1))
+	r.AutoStart = 1
+
+	assert.NoError(t, r.HandleReady(ClientReady{Player: owner, Ready: true}))
+	assert.False(t, r.counting)
+	assert.NoError(t, r.HandleReady(ClientReady{Player: other, Ready: true}))
+	assert.True(t, r.counting, "countdown starts once everyone is ready")
+	assert.Equal(t, 1, receive[*ServerCountdown](t, owner).Seconds)
+
+	assert.NoError(t, r.HandleReady(ClientReady{Player: other, Ready: false}))
+	assert.False(t, r.counting)
+	assert.Zero(t, receive[*ServerCountdown](t, owner).Seconds, "countdown is cancelled")
+
+	// a stale timer does not start the game
+	r.handleAutoStart(autoStart{r.countdown - 1})
+	assert.Equal(t, GamePhaseLobby, r.GamePhase)
+
+	assert.NoError(t, r.HandleReady(ClientReady{Player: other, Ready: true}))
+	r.handleAutoStart(autoStart{r.countdown})
+	assert.Equal(t, GamePhasePlaying, r.GamePhase)
+}
+
+func TestPause(t *testing.T) {
+	owner := newTestPlayer("p_owner")
+	other := newTestPlayer("p_other")
+	r := newTestRoom(t, owner, other)
+	codeOf := func(err error) ErrorCode { return err.(*Error).Code }
+
+	assert.Equal(t, ErrorGameNotPlaying, codeOf(r.HandlePause(ClientPause{Player: owner})))
+
+	r.GamePhase = GamePhasePlaying
+	r.CurrentTurn = 1
+	r.GameStartedAt = time.Now().UnixMilli()
+	r.TurnStartedAt = r.GameStartedAt
+	assert.Equal(t, ErrorNotOwner, codeOf(r.HandlePause(ClientPause{Player: other})))
+	assert.Equal(t, ErrorGameNotPaused, codeOf(r.HandleResume(ClientResume{Player: owner})))
+
+	assert.NoError(t, r.HandlePause(ClientPause{Player: owner}))
+	assert.True(t, r.Paused)
+	assert.False(t, receive[*ServerPause](t, other).Automatic)
+	assert.Equal(t, ErrorGamePaused, codeOf(r.HandlePause(ClientPause{Player: owner})))
+
+	assert.Equal(t, ErrorGamePaused, codeOf(r.HandleDraw(ClientDraw{Player: other})))
+	assert.Equal(t, ErrorGamePaused, codeOf(r.HandleSend(ClientSend{Player: other, RecipientId: owner.Id})))
+	assert.NoError(t, r.HandleChat(ClientChat{Player: other, Message: "brb"}), "chat works while paused")
+
+	// pretend the pause lasted a minute
+	r.PausedAt -= 60_000
+	started := r.GameStartedAt
+	assert.NoError(t, r.HandleResume(ClientResume{Player: owner}))
+	assert.False(t, r.Paused)
+	resume := receive[*ServerResume](t, other)
+	assert.GreaterOrEqual(t, resume.Duration, int64(60_000))
+	assert.Equal(t, started+resume.Duration, r.GameStartedAt, "pause is excluded from the game clock")
+	assert.Equal(t, started+resume.Duration, r.TurnStartedAt, "pause is excluded from the turn clock")
+}
+
+func TestAutoPause(t *testing.T) {
+	owner := newTestPlayer("p_owner")
+	other := newTestPlayer("p_other")
+	r := newTestRoom(t, owner, other)
+	r.GamePhase = GamePhasePlaying
+	r.CurrentTurn = 1
+
+	r.handleDisconnected(playerDisconnected{Player: owner})
+	assert.False(t, r.Paused, "only the current player pauses the game")
+	r.handleReconnected(playerReconnected{Player: owner})
+
+	r.handleDisconnected(playerDisconnected{Player: other})
+	assert.True(t, r.Paused)
+	pause := receive[*ServerPause](t, owner)
+	assert.True(t, pause.Automatic)
+	assert.Equal(t, other.Id, pause.PlayerId)
+
+	r.handleReconnected(playerReconnected{Player: other})
+	assert.False(t, r.Paused, "game resumes when the player is back")
+}
+
+func TestSend(t *testing.T) {
+	sender := newTestPlayer("p_sender")
+	target := newTestPlayer("p_target")
+	r := newTestRoom(t, sender, target)
+	r.GamePhase = GamePhasePlaying
+	send := func() error { return r.HandleSend(ClientSend{Player: sender, RecipientId: target.Id}) }
+
+	assert.Equal(t, ErrorIncompatibleCards, send().(*Error).Code, "empty hands are not compatible")
+	sender.Hand = PlayerHand{{Id: "c_1", Type: card.Star}}
+	assert.Equal(t, ErrorIncompatibleCards, send().(*Error).Code, "empty hands are not compatible")
+
+	target.Hand = PlayerHand{{Id: "c_2", Type: card.Star}}
+	assert.NoError(t, send())
+	assert.Empty(t, sender.Hand)
+	assert.Len(t, target.Hand, 2)
+	assert.Equal(t, 1, target.Score)
+}
@@ -1,11 +1,15 @@
 package game
 
 import (
+	"cardgame/config"
 	"cardgame/deck"
 	"cardgame/util"
+	"cardgame/util/logging"
 	"cardgame/words"
-	"log"
+	"crypto/subtle"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,35 +21,125 @@ type hubMessage struct {
 type Hub struct {
 	RegionCode string
 	Version    string
+	Log        *logging.Logger // parent of the room and player loggers
 
 	Rooms map[string]*Room // RoomId -> Room
 
-	inbound chan *hubMessage // incoming client messages
+	config   *config.Config
+	mu       sync.RWMutex         // guards Rooms, players and draining
+	players  map[*Player]struct{} // connected players, in a room or not
+	draining bool                 // true once Shutdown was called
+	inbound  chan *hubMessage     // incoming client messages
 }
 
+// NewHub returns a hub with the given configuration and starts it.
+func NewHub(cfg *config.Config, log *logging.Logger) *Hub {
+	h := &Hub{
+		RegionCode: "global",
+		Log:        log,
+
+		Rooms:   make(map[string]*Room),
+		config:  cfg,
+		players: make(map[*Player]struct{}),
+		inbound: make(chan *hubMessage),
+	}
+	go h.read()
+	return h
+}
+
+// RoomLimitReached returns true if no more rooms can be created.
+func (h *Hub) RoomLimitReached() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.config.Rooms.MaxRooms > 0 && len(h.Rooms) >= h.config.Rooms.MaxRooms
+}
+
+// NewRoom creates a room and starts handling its messages.
 func (h *Hub) NewRoom(password string) *Room {
-	id := util.IdFrom("r", time.Now().String())
+	return h.NewRoomWithId(util.IdFrom("r", time.Now().String()), password)
+}
+
+// NewRoomWithId creates a room with a fixed id, such as the debug room, and
+// starts handling its messages. The id must not be in use.
+func (h *Hub) NewRoomWithId(id, password string) *Room {
+	r := h.createRoom(id, password)
+	go r.read()
+	return r
+}
+
+// newRoom creates a room and adds it to the hub without starting its read
+// goroutine.
+func (h *Hub) newRoom(password string) *Room {
+	return h.createRoom(util.IdFrom("r", time.Now().String()), password)
+}
+
+func (h *Hub) createRoom(id, password string) *Room {
 	r := Room{
 		Id:         id,
 		Name:       strings.Join(words.Words(words.English, 4), " "),
 		Timstamp:   time.Now().UnixMilli(),
 		Players:    []*Player{},
 		Decks:      []*deck.Deck{},
-		MaxPlayers: 4,
+		MaxPlayers: h.config.Rooms.MaxPlayers,
+		hub:        h,
 		inbound:    make(chan ClientMessage),
-		outbound:   make(chan *serverPayload),
+		done:       make(chan struct{}),
+		log:        h.Log.With("room", id),
 	}
+	h.mu.Lock()
 	h.Rooms[r.Id] = &r
+	h.mu.Unlock()
 	if password != "" {
 		r.SetPassword(password)
 	}
 
-	go r.read()
-	go r.write()
-
+	r.log.Info("room created", "private", r.private)
 	return &r
 }
 
+// Room returns the room with the given id, or nil.
+func (h *Hub) Room(id string) *Room {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.Rooms[id]
+}
+
+// AllRooms returns all rooms, including private ones, sorted by creation time.
+func (h *Hub) AllRooms() []*Room {
+	h.mu.RLock()
+	rooms := make([]*Room, 0, len(h.Rooms))
+	for _, r := range h.Rooms {
+		rooms = append(rooms, r)
+	}
+	h.mu.RUnlock()
+
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].Timstamp < rooms[j].Timstamp })
+	return rooms
+}
+
+// Players returns all connected players.
+func (h *Hub) Players() []*Player {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	players := make([]*Player, 0, len(h.players))
+	for p := range h.players {
+		players = append(players, p)
+	}
+	return players
+}
+
+func (h *Hub) addPlayer(p *Player) {
+	h.mu.Lock()
+	h.players[p] = struct{}{}
+	h.mu.Unlock()
+}
+
+func (h *Hub) removePlayer(p *Player) {
+	h.mu.Lock()
+	delete(h.players, p)
+	h.mu.Unlock()
+}
+
 func (h *Hub) read() {
 	for {
 		select {
@@ -60,7 +154,8 @@ func (h *Hub) read() {
 			case ClientLeave:
 				h.handleLeave(clientMessage)
 			default:
-				log.Printf("[error] bad message type %T sent to hub\n", clientMessage)
+				h.Log.Debug("message rejected", "type", clientMessage.ClientType(), "player", msg.player.Id, "code", ErrorNotInRoom)
+				msg.player.send(serverError(clientMessage, newError(ErrorNotInRoom, "You are not in a room")))
 			}
 		}
 	}
@@ -68,41 +163,62 @@ func (h *Hub) read() {
 
 func (h *Hub) handleJoin(msg ClientJoin) {
 	p := msg.Player
-	r, ok := h.Rooms[msg.RoomId]
+	r := h.Room(msg.RoomId)
 
-	if p.room != nil {
-		p.outbound <- &ServerError{"You are already in a room"}
+	if p.currentRoom() != nil {
+		p.send(serverError(msg, newError(ErrorAlreadyInRoom, "You are already in a room")))
 		return
 	}
 
-	if r == nil || !ok {
-		p.outbound <- &ServerError{"Room not found"}
+	if r == nil {
+		p.send(serverError(msg, newError(ErrorRoomNotFound, "Room not found")))
+		return
+	}
+
+	if h.Draining() {
+		p.send(serverError(msg, newError(ErrorShuttingDown, "The server is shutting down")))
 		return
 	}
 
 	if r.IsPrivate() && !r.CheckPassword(msg.Password) {
-		p.outbound <- &ServerError{"Incorrect password"}
+		p.send(serverError(msg, newError(ErrorIncorrectPassword, "Incorrect password")))
 		return
 	}
 
-	r.inbound <- msg
+	r.post(msg)
 }
 
 func (h *Hub) handleLeave(msg ClientLeave) {
-	if msg.Player.room != nil {
-		msg.Player.room.inbound <- msg
-		msg.Player.room = nil
+	r := msg.Player.currentRoom()
+	if r == nil {
+		msg.Player.send(serverError(msg, newError(ErrorNotInRoom, "You are not in a room")))
+		return
 	}
+	r.post(msg)
+	msg.Player.setRoom(nil)
 }
 
-var HubMain *Hub
+// reconnectTarget returns the player that p is trying to reconnect as.
+func (h *Hub) reconnectTarget(p *Player, msg ClientReconnect) (*Player, error) {
+	if p.currentRoom() != nil {
+		return nil, newError(ErrorAlreadyInRoom, "You are already in a room")
+	}
 
-func init() {
-	HubMain = &Hub{
-		RegionCode: "global",
+	r := h.Room(msg.RoomId)
+	if r == nil {
+		return nil, newError(ErrorRoomNotFound, "Room not found")
+	}
 
-		Rooms:   make(map[string]*Room),
-		inbound: make(chan *hubMessage),
+	r.mu.Lock()
+	target := r.getMember(msg.PlayerId)
+	r.mu.Unlock()
+	if target == nil || subtle.ConstantTimeCompare([]byte(target.session), []byte(msg.Token)) != 1 {
+		return nil, newError(ErrorInvalidSession, "Invalid session")
 	}
-	go HubMain.read()
+
+	return target, nil
 }
+
+// HubMain is the hub used by the server. It starts with the default
+// configuration and is replaced on startup.
+var HubMain = NewHub(config.Default(), logging.Default)
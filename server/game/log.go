@@ -0,0 +1,50 @@
+package game
+
+import (
+	"reflect"
+	"strings"
+)
+
+// messageFields returns the scalar fields of a client message as key-value
+// pairs for logging, keyed by their JSON names. Sensitive fields such as
+// passwords and chat text are redacted by the logger.
+func messageFields(message ClientMessage) []any {
+	v := reflect.ValueOf(message)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := []any{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" || name == "" {
+			continue
+		}
+
+		value := v.Field(i)
+		if value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				continue
+			}
+			value = value.Elem()
+		}
+		switch value.Kind() {
+		case reflect.String:
+			fields = append(fields, name, value.String())
+		case reflect.Bool:
+			fields = append(fields, name, value.Bool())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			fields = append(fields, name, value.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			fields = append(fields, name, value.Uint())
+		case reflect.Float32, reflect.Float64:
+			fields = append(fields, name, value.Float())
+		}
+	}
+	return fields
+}
@@ -0,0 +1,23 @@
+package game
+
+import (
+	"bytes"
+	"cardgame/util/logging"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageFields(t *testing.T) {
+	name := "Lobby"
+	fields := messageFields(ClientChangeDetails{Name: &name, RequestId: "q_1"})
+	assert.Equal(t, []any{"name", "Lobby"}, fields)
+
+	var buf bytes.Buffer
+	l := logging.New(&buf, logging.LevelDebug, logging.FormatLogfmt)
+	l.Debug("message received", messageFields(ClientJoin{RoomId: "r_1", Password: "hunter2"})...)
+	l.Debug("message received", messageFields(ClientChat{Message: "hello there"})...)
+	assert.Contains(t, buf.String(), "roomId=r_1")
+	assert.NotContains(t, buf.String(), "hunter2")
+	assert.NotContains(t, buf.String(), "hello there")
+}
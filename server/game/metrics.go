@@ -0,0 +1,89 @@
+package game
+
+import (
+	"cardgame/deck"
+	"cardgame/util/metrics"
+	"strings"
+)
+
+var (
+	messagesIn = metrics.Default.CounterVec("cardgame_messages_in_total",
+		"Client messages received, by type.", "type")
+	messagesOut = metrics.Default.CounterVec("cardgame_messages_out_total",
+		"Server messages delivered to players, by type.", "type")
+	handlerDuration = metrics.Default.HistogramVec("cardgame_handler_duration_seconds",
+		"Time spent in Room.HandleMessage, including waiting for the room, by message type.",
+		metrics.DefaultBuckets, "type")
+	errorsSent = metrics.Default.CounterVec("cardgame_errors_total",
+		"Errors sent to players, by code.", "code")
+	queueDropped = metrics.Default.CounterVec("cardgame_queue_dropped_total",
+		"Messages dropped from player queues, non-critical or discarded from a full queue.").With()
+	queueCoalesced = metrics.Default.CounterVec("cardgame_queue_coalesced_total",
+		"Queued resyncs replaced by newer ones.").With()
+	queueOverflows = metrics.Default.CounterVec("cardgame_queue_overflows_total",
+		"Times a player was disconnected for falling behind.").With()
+)
+
+func init() {
+	// export every known type, even before it is first seen
+	for t := range ClientMessageTypes {
+		messagesIn.With(t)
+		handlerDuration.With(t)
+	}
+	for t := range ServerMessageTypes {
+		messagesOut.With(t)
+	}
+	for _, c := range TSAllErrorCodes {
+		errorsSent.With(string(c.Value))
+	}
+
+	metrics.Default.Gauge("cardgame_websockets", "Open websocket connections.", func() float64 {
+		n := 0
+		for _, p := range HubMain.Players() {
+			if p.hasSocket() {
+				n++
+			}
+		}
+		return float64(n)
+	})
+	metrics.Default.Gauge("cardgame_players", "Players, including those waiting to reconnect.", func() float64 {
+		return float64(len(HubMain.Players()))
+	})
+	metrics.Default.GaugeFunc("cardgame_rooms", "Rooms, by game phase.", func() []metrics.Sample {
+		counts := map[GamePhase]int{}
+		for _, r := range HubMain.AllRooms() {
+			r.mu.Lock()
+			counts[r.GamePhase]++
+			r.mu.Unlock()
+		}
+		samples := []metrics.Sample{}
+		for _, phase := range TSAllGamePhases {
+			samples = append(samples, metrics.Sample{
+				Values: []string{strings.ToLower(phase.TSName)},
+				Value:  float64(counts[phase.Value]),
+			})
+		}
+		return samples
+	}, "phase")
+	metrics.Default.GaugeFunc("cardgame_queue", "Outbound queue depth, see QueueStatistics.", func() []metrics.Sample {
+		stats := HubMain.QueueStats()
+		return []metrics.Sample{
+			{Values: []string{"queued"}, Value: float64(stats.Queued)},
+			{Values: []string{"max_depth"}, Value: float64(stats.MaxDepth)},
+		}
+	}, "stat")
+	metrics.Default.Gauge("cardgame_decks", "Loaded decks.", func() float64 {
+		return float64(len(deck.Decks()))
+	})
+	metrics.Default.GaugeFunc("cardgame_deck_cards", "Cards in all loaded decks, by kind.", func() []metrics.Sample {
+		cards, wildCards := 0, 0
+		for _, d := range deck.Decks() {
+			cards += len(d.Cards)
+			wildCards += len(d.WildCards)
+		}
+		return []metrics.Sample{
+			{Values: []string{"card"}, Value: float64(cards)},
+			{Values: []string{"wild"}, Value: float64(wildCards)},
+		}
+	}, "kind")
+}
@@ -4,7 +4,6 @@ import (
 	"cardgame/util/slices"
 	"encoding/json"
 	"errors"
-	"log"
 	"reflect"
 )
 
@@ -12,90 +11,235 @@ type set map[string]struct{}
 
 type (
 	clientPayload struct {
-		Type string `json:"type"`
+		Type      string `json:"type"`
+		RequestId string `json:"requestId"`
 	}
 
+	// ClientMessage is a message sent by a client. Every client message has a
+	// Player, the sender, and a RequestId, an optional id chosen by the client
+	// that is echoed in the resulting ServerAck or ServerError. Both are set
+	// by ClientMessageFromJson.
 	ClientMessage interface{ ClientType() string }
 
 	// ClientChangeDetails is sent by the room owner to change the room details and add/remove decks
 	ClientChangeDetails struct {
-		Player *Player `json:"-"`
+		Player    *Player `json:"-"`
+		RequestId string  `json:"-"`
 
-		Name        *string   `json:"name"`
-		Description *string   `json:"description"`
-		MaxPlayers  *int      `json:"maxPlayers"`
-		Password    *string   `json:"password"`    // new password for private rooms, or "" for public rooms
-		AddDecks    []string  `json:"addDecks"`    // IDs of decks to add
-		RemoveDecks []string  `json:"removeDecks"` // IDs of decks to remove
-		PlayMode    *PlayMode `json:"playMode"`    // new play mode
-		HubDeviceId *string   `json:"hubDeviceId"` // ID of the hub device to use
+		Name        *string     `json:"name"`
+		Description *string     `json:"description"`
+		MaxPlayers  *int        `json:"maxPlayers"`
+		Password    *string     `json:"password"`    // new password for private rooms, or "" for public rooms
+		AddDecks    []string    `json:"addDecks"`    // IDs of decks to add
+		RemoveDecks []string    `json:"removeDecks"` // IDs of decks to remove
+		PlayMode    *PlayMode   `json:"playMode"`    // new play mode
+		HubDeviceId *string     `json:"hubDeviceId"` // ID of the hub device to use
+		SlowMode    *int        `json:"slowMode"`    // minimum seconds between chat messages of a player, 0 to disable
+		LeaveCards  *LeaveCards `json:"leaveCards"`  // what happens to the cards of a player leaving mid-game
+		LateJoin    *bool       `json:"lateJoin"`    // whether players joining mid-game take part instead of spectating
+		AutoStart   *int        `json:"autoStart"`   // seconds until the game starts once everyone is ready, 0 to disable
 	}
 	// ClientJoin is sent to the hub by a new player joining a room.
 	ClientJoin struct {
-		Player *Player `json:"-"`
+		Player    *Player `json:"-"`
+		RequestId string  `json:"-"`
 
 		RoomId   string `json:"roomId"`
 		Password string `json:"password"`
+		Name     string `json:"name"` // display name, or "" to keep the generated one
 	}
 	// ClientLeave is sent by a player leaving the room.
 	ClientLeave struct {
-		Player *Player `json:"-"`
+		Player    *Player `json:"-"`
+		RequestId string  `json:"-"`
 	}
 	// ClientKick is sent by the room owner to kick a player.
 	ClientKick struct {
-		Player *Player `json:"-"`
+		Player    *Player `json:"-"`
+		RequestId string  `json:"-"`
+
+		Id  string `json:"id"`
+		Ban bool   `json:"ban"` // also prevent the player from rejoining
+	}
+	// ClientVoteKick is sent by a player to vote for kicking another player
+	// while the owner is absent.
+	ClientVoteKick struct {
+		Player    *Player `json:"-"`
+		RequestId string  `json:"-"`
 
 		Id string `json:"id"`
 	}
+	// ClientTransferOwnership is sent by the room owner to make another player
+	// the owner.
+	ClientTransferOwnership struct {
+		Player    *Player `json:"-"`
+		RequestId string  `json:"-"`
+
+		Id string `json:"id"`
+	}
+	// ClientReady is sent by a player in the lobby to mark themselves as ready
+	// or not ready to start.
+	ClientReady struct {
+		Player    *Player `json:"-"`
+		RequestId string  `json:"-"`
+
+		Ready bool `json:"ready"`
+	}
 	// ClientStart is sent by the room owner to start the game.
 	ClientStart struct {
-		Player *Player `json:"-"`
+		Player    *Player `json:"-"`
+		RequestId string  `json:"-"`
+	}
+	// ClientPause is sent by the room owner to pause the game.
+	ClientPause struct {
+		Player    *Player `json:"-"`
+		RequestId string  `json:"-"`
+	}
+	// ClientResume is sent by the room owner to resume a paused game.
+	ClientResume struct {
+		Player    *Player `json:"-"`
+		RequestId string  `json:"-"`
 	}
 	// ClientDraw is sent by a player to draw a card.
 	ClientDraw struct {
-		Player *Player `json:"-"`
+		Player    *Player `json:"-"`
+		RequestId string  `json:"-"`
 	}
 	// ClientSend is sent by a player to send a card to another player.
 	ClientSend struct {
-		Player *Player `json:"-"`
+		Player    *Player `json:"-"`
+		RequestId string  `json:"-"`
 
 		RecipientId string `json:"recipientId"`
 	}
 	// ClientChat is sent by a player to send a chat message.
 	ClientChat struct {
-		Player *Player `json:"-"`
+		Player    *Player `json:"-"`
+		RequestId string  `json:"-"`
 
 		Message     string  `json:"message"`
 		RecipientId *string `json:"recipient"` // RecipientId is set if the message is a private message.
 	}
+	// ClientDeleteChat is sent by the room owner to delete a chat message.
+	ClientDeleteChat struct {
+		Player    *Player `json:"-"`
+		RequestId string  `json:"-"`
+
+		Id string `json:"id"` // id of the chat message
+	}
+	// ClientMute is sent by the room owner to mute a player in the chat.
+	ClientMute struct {
+		Player    *Player `json:"-"`
+		RequestId string  `json:"-"`
+
+		Id       string `json:"id"`       // id of the player
+		Duration int    `json:"duration"` // seconds, or 0 to unmute
+	}
+	// ClientRequestSnapshot is sent by a player to request the full room state,
+	// e.g. after detecting a gap in the state versions.
+	ClientRequestSnapshot struct {
+		Player    *Player `json:"-"`
+		RequestId string  `json:"-"`
+	}
+	// ClientHello must be the first message sent on every connection.
+	ClientHello struct {
+		Player    *Player `json:"-"`
+		RequestId string  `json:"-"`
+
+		ProtocolVersion int      `json:"protocolVersion"`
+		Capabilities    []string `json:"capabilities"` // optional features supported by the client
+	}
+	// ClientAck is sent by a player to acknowledge all messages up to and
+	// including Seq, so the server can stop buffering them.
+	ClientAck struct {
+		Player    *Player `json:"-"`
+		RequestId string  `json:"-"`
+
+		Seq int `json:"seq"`
+	}
+	// ClientReconnect is sent on a new connection to take over a player whose
+	// connection dropped. Messages sent after LastSeq are replayed.
+	ClientReconnect struct {
+		Player    *Player `json:"-"`
+		RequestId string  `json:"-"`
+
+		RoomId   string `json:"roomId"`
+		PlayerId string `json:"playerId"`
+		Token    string `json:"token"`   // token from ServerSession
+		LastSeq  int    `json:"lastSeq"` // last sequence number received
+	}
 )
 
-func (c ClientChangeDetails) ClientType() string { return "change_details" }
-func (c ClientJoin) ClientType() string          { return "join" }
-func (c ClientLeave) ClientType() string         { return "leave" }
-func (c ClientKick) ClientType() string          { return "kick" }
-func (c ClientStart) ClientType() string         { return "start" }
-func (c ClientDraw) ClientType() string          { return "draw" }
-func (c ClientSend) ClientType() string          { return "send" }
-func (c ClientChat) ClientType() string          { return "chat" }
+// Internal messages sent to the room by the server itself.
+type (
+	// playerDisconnected is sent when a player's connection drops.
+	playerDisconnected struct{ Player *Player }
+	// playerReconnected is sent when a player resumes their session.
+	playerReconnected struct{ Player *Player }
+	// autoStart is sent when an auto-start countdown has finished.
+	autoStart struct {
+		countdown int // countdown generation when the timer was started
+	}
+	// playerTimedOut is sent when a player has not reconnected in time.
+	playerTimedOut struct {
+		Player      *Player
+		disconnects int // disconnect count when the timer was started
+	}
+)
+
+func (c ClientChangeDetails) ClientType() string     { return "change_details" }
+func (c ClientJoin) ClientType() string              { return "join" }
+func (c ClientLeave) ClientType() string             { return "leave" }
+func (c ClientVoteKick) ClientType() string          { return "vote_kick" }
+func (c ClientKick) ClientType() string              { return "kick" }
+func (c ClientTransferOwnership) ClientType() string { return "transfer_ownership" }
+func (c ClientReady) ClientType() string             { return "ready" }
+func (c ClientStart) ClientType() string             { return "start" }
+func (c ClientPause) ClientType() string             { return "pause" }
+func (c ClientResume) ClientType() string            { return "resume" }
+func (c ClientDraw) ClientType() string              { return "draw" }
+func (c ClientSend) ClientType() string              { return "send" }
+func (c ClientChat) ClientType() string              { return "chat" }
+func (c ClientDeleteChat) ClientType() string        { return "delete_chat" }
+func (c ClientMute) ClientType() string              { return "mute" }
+func (c ClientRequestSnapshot) ClientType() string   { return "request_snapshot" }
+func (c ClientHello) ClientType() string             { return "hello" }
+func (c ClientAck) ClientType() string               { return "ack" }
+func (c ClientReconnect) ClientType() string         { return "reconnect" }
+
+func (c playerDisconnected) ClientType() string { return "disconnected" }
+func (c playerReconnected) ClientType() string  { return "reconnected" }
+func (c autoStart) ClientType() string          { return "auto_start" }
+func (c playerTimedOut) ClientType() string     { return "timed_out" }
 
 var ClientMessageTypes = slices.AssociateReverseBy([]ClientMessage{
 	ClientChangeDetails{},
 	ClientJoin{},
 	ClientLeave{},
 	ClientKick{},
+	ClientVoteKick{},
+	ClientTransferOwnership{},
+	ClientReady{},
 	ClientStart{},
+	ClientPause{},
+	ClientResume{},
 	ClientDraw{},
 	ClientSend{},
 	ClientChat{},
+	ClientDeleteChat{},
+	ClientMute{},
+	ClientRequestSnapshot{},
+	ClientHello{},
+	ClientAck{},
+	ClientReconnect{},
 }, func(t ClientMessage) string { return t.ClientType() })
 
 // ClientMessageFromJson converts a byte slice into a ClientMessage.
+//
 //go:todo avoid unmarshalling twice?
 func (p *Player) ClientMessageFromJson(data []byte) (msg ClientMessage, err error) {
 	var payload clientPayload
 	if err := json.Unmarshal(data, &payload); err != nil {
-		log.Printf("error: %v", err)
 		return nil, err
 	}
 
@@ -103,10 +247,10 @@ func (p *Player) ClientMessageFromJson(data []byte) (msg ClientMessage, err erro
 		if payload.Type == name {
 			c := reflect.New(reflect.TypeOf(typeVal))
 			if err := json.Unmarshal(data, c.Interface()); err != nil {
-				log.Printf("error: %v", err)
 				return nil, err
 			}
 			c.Elem().FieldByName("Player").Set(reflect.ValueOf(p))
+			c.Elem().FieldByName("RequestId").SetString(payload.RequestId)
 			return c.Elem().Interface().(ClientMessage), nil
 		}
 	}
@@ -25,23 +25,86 @@ type (
 	}
 	// ServerJoin is sent to all players when a new player joins the room.
 	ServerJoin struct {
-		Id     string `json:"id"`
-		Player Player `json:"player"`
+		Id        string  `json:"id"`
+		Player    *Player `json:"player"`
+		Spectator bool    `json:"spectator"` // true if the player joined as a spectator
 	}
-	// ServerAck is sent to a player when they join the room.
+	// ServerAck is sent to a player when their client message was handled
+	// successfully.
 	ServerAck struct {
+		Action    string `json:"action"`              // type of the client message
+		RequestId string `json:"requestId,omitempty"` // request id of the client message
 	}
 	// ServerLeave is sent to all players when a player leaves the room.
 	ServerLeave struct {
-		Id string `json:"id"`
+		Id     string `json:"id"`
+		Kicked bool   `json:"kicked"` // true if the player was kicked
+	}
+	// ServerRoomClosed is sent to all players when the room is closed by the
+	// server. The players are no longer in a room afterwards.
+	ServerRoomClosed struct {
+		Reason string `json:"reason"`
+	}
+	// ServerAnnouncement is sent to every connected player for server-wide
+	// announcements, e.g. upcoming maintenance.
+	ServerAnnouncement struct {
+		Timestamp int64  `json:"timestamp"` // unix milliseconds
+		Message   string `json:"message"`
+	}
+	// ServerShutdown is sent to every connected player when the server is
+	// shutting down. Connections are closed by Deadline; clients should wait
+	// ReconnectAfter before reconnecting with their session.
+	ServerShutdown struct {
+		Reason         string `json:"reason"`
+		Deadline       int64  `json:"deadline"`       // unix milliseconds
+		ReconnectAfter int    `json:"reconnectAfter"` // milliseconds
+	}
+	// ServerOwnerChanged is sent to all players when the room gets a new owner,
+	// either by a transfer or because the previous owner left.
+	ServerOwnerChanged struct {
+		OwnerId    string `json:"ownerId"`
+		PreviousId string `json:"previousId"`
 	}
 	// ServerKick is sent to a player when they are kicked from the room.
 	ServerKick struct {
+		Banned bool `json:"banned"` // true if the player cannot rejoin
+	}
+	// ServerVoteKick is sent to all players when a player votes to kick another.
+	ServerVoteKick struct {
+		PlayerId string `json:"playerId"` // player to be kicked
+		VoterId  string `json:"voterId"`
+		Votes    int    `json:"votes"`
+		Required int    `json:"required"` // votes needed to kick the player
+	}
+	// ServerReady is sent to all players when a player changes their ready state.
+	ServerReady struct {
+		PlayerId string `json:"playerId"`
+		Ready    bool   `json:"ready"`
+	}
+	// ServerCountdown is sent to all players when an auto-start countdown
+	// starts or is cancelled.
+	ServerCountdown struct {
+		Seconds  int   `json:"seconds"`  // 0 if the countdown was cancelled
+		StartsAt int64 `json:"startsAt"` // unix milliseconds, 0 if cancelled
 	}
 	// ServerStart is sent to all players when the game starts.
 	ServerStart struct {
 		CurrentTurn int `json:"currentTurn"`
 	}
+	// ServerEnd is sent to all players when the game ends.
+	ServerEnd struct {
+		Reason string `json:"reason"` // e.g. "not_enough_players"
+	}
+	// ServerPause is sent to all players when the game is paused.
+	ServerPause struct {
+		PlayerId  string `json:"playerId"`  // player who paused the game
+		Automatic bool   `json:"automatic"` // true if paused because the current player disconnected
+	}
+	// ServerResume is sent to all players when the game is resumed.
+	ServerResume struct {
+		PlayerId string `json:"playerId"` // player who resumed the game, empty if automatic
+		Duration int64  `json:"duration"` // milliseconds the game was paused for
+	}
 	// ServerDraw is sent to all players when a player draws a card.
 	ServerDraw struct {
 		PlayerId string     `json:"playerId"`
@@ -65,11 +128,27 @@ type (
 	}
 	// ServerChat is sent to all players when a chat message is sent.
 	ServerChat struct {
+		Id        string `json:"id"`
 		Timestamp string `json:"timestamp"`
 		PlayerId  string `json:"player"`
 		Private   bool   `json:"private"`
+		System    bool   `json:"system"` // sent by the server, e.g. a command reply
 		Message   string `json:"message"`
 	}
+	// ServerChatHistory is sent to a player when they join the room, with the
+	// most recent public chat messages.
+	ServerChatHistory struct {
+		Messages []*ServerChat `json:"messages"` // oldest first
+	}
+	// ServerChatDeleted is sent to all players when the owner deletes a chat message.
+	ServerChatDeleted struct {
+		Id string `json:"id"`
+	}
+	// ServerMute is sent to all players when the owner mutes or unmutes a player.
+	ServerMute struct {
+		PlayerId string `json:"playerId"`
+		Until    int64  `json:"until"` // unix milliseconds, or 0 if unmuted
+	}
 	// ServerResync is sent to all players occasionally to resync the top cards of the hands.
 	ServerResync struct {
 		TopCards map[string]*card.Card `json:"topCards"` // playerId -> card
@@ -78,9 +157,42 @@ type (
 	ServerTurn struct {
 		PlayerId string `json:"playerId"`
 	}
+	// ServerSnapshot is sent to a player when they join the room or request a
+	// snapshot. The full room state is included with the message.
+	ServerSnapshot struct {
+	}
+	// ServerHello is sent in response to ClientHello.
+	ServerHello struct {
+		ProtocolVersion int      `json:"protocolVersion"`
+		Version         string   `json:"version"`  // server version
+		Commit          string   `json:"commit"`   // server commit
+		Features        []string `json:"features"` // optional features supported by the server
+	}
+	// ServerSession is sent to a player when they join the room, with the
+	// token needed to reconnect as the same player.
+	ServerSession struct {
+		PlayerId string `json:"playerId"`
+		Token    string `json:"token"`
+	}
+	// ServerReconnect is sent to a player after a successful reconnect, once
+	// the missed messages have been replayed. If they could not be replayed, a
+	// snapshot is sent instead.
+	ServerReconnect struct {
+		Replayed int  `json:"replayed"` // number of messages replayed
+		Snapshot bool `json:"snapshot"` // true if a snapshot was sent instead
+	}
+	// ServerPresence is sent to all players when a player disconnects or reconnects.
+	ServerPresence struct {
+		Id        string `json:"id"`
+		Connected bool   `json:"connected"`
+	}
 	// ServerError is sent to a player when an error occurs.
 	ServerError struct {
-		Message string `json:"message"`
+		Code      ErrorCode      `json:"code"`
+		Message   string         `json:"message"`                                          // human-readable description
+		Details   map[string]any `json:"details,omitempty" ts_type:"{[key: string]: any}"` // additional information, depending on the code
+		Request   string         `json:"request,omitempty"`                                // type of the client message that caused the error
+		RequestId string         `json:"requestId,omitempty"`                              // request id of the client message that caused the error
 	}
 )
 
@@ -88,29 +200,86 @@ func (s ServerChangeDetails) ServerType() string { return "change_details" }
 func (s ServerJoin) ServerType() string          { return "join" }
 func (s ServerAck) ServerType() string           { return "ack" }
 func (s ServerLeave) ServerType() string         { return "leave" }
+func (s ServerVoteKick) ServerType() string      { return "vote_kick" }
 func (s ServerKick) ServerType() string          { return "kick" }
+func (s ServerReady) ServerType() string         { return "ready" }
+func (s ServerCountdown) ServerType() string     { return "countdown" }
+func (s ServerPause) ServerType() string         { return "pause" }
+func (s ServerResume) ServerType() string        { return "resume" }
+func (s ServerEnd) ServerType() string           { return "end" }
+func (s ServerRoomClosed) ServerType() string    { return "room_closed" }
+func (s ServerAnnouncement) ServerType() string  { return "announcement" }
+func (s ServerShutdown) ServerType() string      { return "shutdown" }
+func (s ServerOwnerChanged) ServerType() string  { return "owner_changed" }
 func (s ServerStart) ServerType() string         { return "start" }
 func (s ServerDraw) ServerType() string          { return "draw" }
 func (s ServerWildCard) ServerType() string      { return "wild_card" }
 func (s ServerReshuffle) ServerType() string     { return "reshuffle" }
 func (s ServerSend) ServerType() string          { return "send" }
 func (s ServerChat) ServerType() string          { return "chat" }
+func (s ServerChatHistory) ServerType() string   { return "chat_history" }
+func (s ServerChatDeleted) ServerType() string   { return "chat_deleted" }
+func (s ServerMute) ServerType() string          { return "mute" }
 func (s ServerResync) ServerType() string        { return "resync" }
 func (s ServerTurn) ServerType() string          { return "turn" }
+func (s ServerSnapshot) ServerType() string      { return "snapshot" }
+func (s ServerHello) ServerType() string         { return "hello" }
+func (s ServerSession) ServerType() string       { return "session" }
+func (s ServerReconnect) ServerType() string     { return "reconnect" }
+func (s ServerPresence) ServerType() string      { return "presence" }
 func (s ServerError) ServerType() string         { return "error" }
 
+// closeConnection is sent to a player's write goroutine to close the
+// connection with the given close code.
+type closeConnection struct {
+	code   int
+	reason string
+}
+
+func (s closeConnection) ServerType() string { return "close" }
+
+// playerGone is sent to a player's write goroutine when they leave the room.
+type playerGone struct{}
+
+func (s playerGone) ServerType() string { return "gone" }
+
+// messagesDropped takes the place of the messages discarded from the queue of
+// a player who fell too far behind.
+type messagesDropped struct{}
+
+func (s messagesDropped) ServerType() string { return "dropped" }
+
 var ServerMessageTypes = slices.AssociateReverseBy([]ServerMessage{
 	ServerChangeDetails{},
 	ServerJoin{},
 	ServerAck{},
 	ServerLeave{},
 	ServerKick{},
+	ServerVoteKick{},
+	ServerOwnerChanged{},
+	ServerRoomClosed{},
+	ServerAnnouncement{},
+	ServerShutdown{},
+	ServerReady{},
+	ServerCountdown{},
 	ServerStart{},
+	ServerEnd{},
+	ServerPause{},
+	ServerResume{},
 	ServerDraw{},
 	ServerWildCard{},
 	ServerReshuffle{},
+	ServerSend{},
 	ServerChat{},
+	ServerChatHistory{},
+	ServerChatDeleted{},
+	ServerMute{},
 	ServerResync{},
 	ServerTurn{},
+	ServerSnapshot{},
+	ServerHello{},
+	ServerSession{},
+	ServerReconnect{},
+	ServerPresence{},
 	ServerError{},
 }, func(t ServerMessage) string { return t.ServerType() })
@@ -3,11 +3,12 @@ package game
 import (
 	"cardgame/card"
 	"cardgame/util"
+	"cardgame/util/logging"
+	"cardgame/util/slices"
 	"cardgame/words"
-	"encoding/json"
-	"fmt"
-	"log"
+	"net"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fatih/structs"
@@ -15,28 +16,52 @@ import (
 )
 
 const (
-	// Time allowed to write a message to the peer.
-	writeWait = 10 * time.Second
-
-	// Time allowed to read the next pong message from the peer.
-	pongWait = 60 * time.Second
-
-	// Send pings to peer with this period. Must be less than pongWait.
-	pingPeriod = (pongWait * 9) / 10
-
 	// Maximum message size allowed from peer. (1MB)
 	maxMessageSize = 1 << 20
+
+	// Number of sent messages kept for replay after a reconnect.
+	resendBufferSize = 256
 )
 
 type Player struct {
-	Id       string       `json:"id"`
-	Avatar   AvatarConfig `json:"avatar"`
-	Name     string       `json:"name"`
-	Score    int          `json:"score"`
-	Hand     PlayerHand   `json:"cards"` // Player's hand, top is at the end
-	socket   *websocket.Conn
-	room     *Room
-	outbound chan ServerMessage // outgoing server messages
+	Id         string          `json:"id"`
+	Avatar     AvatarConfig    `json:"avatar"`
+	Name       string          `json:"name"`
+	Score      int             `json:"score"`
+	Hand       PlayerHand      `json:"cards"`      // Player's hand, top is at the end
+	Connected  bool            `json:"connected"`  // false while waiting for the player to reconnect
+	MutedUntil int64           `json:"mutedUntil"` // unix milliseconds until which the player cannot chat
+	Ready      bool            `json:"ready"`      // ready to start the next game
+	socket     *websocket.Conn // current connection, nil while disconnected
+	room       *Room           // room the player is in, guarded by mu
+	hub        *Hub
+	log        *logging.Logger // logger with the player id
+	outbound   *sendQueue      // outgoing server messages
+	mu         sync.Mutex      // guards socket for closeSocket, and room
+
+	codec        codec                  // wire format of the current connection
+	capabilities []string               // capabilities announced in the client's hello
+	session      string                 // token required to reconnect as this player
+	address      string                 // hashed network address of the client, for bans by address
+	seq          int                    // sequence number of the last message sent
+	sent         *resendBuffer          // recently sent messages, for replay
+	disconnects  int                    // number of times the player has disconnected
+	reconnect    chan *reconnectRequest // connections taking over this player
+	dropped      chan *websocket.Conn   // connections whose read loop has ended
+	handoff      chan chan struct{}     // requests to release the connection to another player
+	done         chan struct{}          // closed when the write goroutine exits
+
+	lastChat time.Time // time of the player's last chat message, for slow mode
+	joined   int       // position in the order players joined the room, for owner migration
+
+	state *stateUpdate // last room state sent to the player
+}
+
+// reconnectRequest attaches a new connection to an existing player.
+type reconnectRequest struct {
+	socket       *websocket.Conn
+	lastSeq      int      // last sequence number the client received
+	capabilities []string // capabilities from the new connection's hello
 }
 
 type PlayerHand []*card.Card
@@ -68,104 +93,405 @@ type AvatarConfig struct {
 // The application runs read in a per-connection goroutine. The application
 // ensures that there is at most one reader on a connection by executing all
 // reads from this goroutine.
-func (p *Player) read() {
+//
+// The first message on every connection must be a ClientHello. greeted is true
+// if it has already been received, e.g. before a reconnect.
+func (p *Player) read(socket *websocket.Conn, greeted bool) {
+	handedOff := false
 	defer func() {
-		p.room.inbound <- ClientLeave{p}
-		p.socket.Close()
+		if handedOff {
+			// the socket now belongs to another player
+			return
+		}
+		select {
+		case p.dropped <- socket:
+		case <-p.done:
+			socket.Close()
+		}
 	}()
-	p.socket.SetReadLimit(maxMessageSize)
-	p.socket.SetReadDeadline(time.Now().Add(pongWait))
-	p.socket.SetPongHandler(func(string) error { p.socket.SetReadDeadline(time.Now().Add(pongWait)); return nil })
+	codec := codecFor(socket)
+	limiter := newRateLimiter(p.hub.config.RateLimits, time.Now())
+	socket.SetReadLimit(maxMessageSize)
+	pongWait := p.hub.config.Timeouts.Pong
+	socket.SetReadDeadline(time.Now().Add(pongWait))
+	socket.SetPongHandler(func(string) error { socket.SetReadDeadline(time.Now().Add(pongWait)); return nil })
 	for {
-		_, mesageData, err := p.socket.ReadMessage()
+		_, rawData, err := socket.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("error: %v", err)
+				p.log.Warn("unexpected close", "error", err)
 			}
 			break
 		}
 
+		mesageData, err := codec.decode(rawData)
+		if err != nil {
+			p.log.Debug("invalid message", "error", err)
+			p.send(serverError(nil, newError(ErrorInvalidMessage, err.Error())))
+			return
+		}
+
 		msg, err := p.ClientMessageFromJson(mesageData)
 		if err != nil {
-			log.Printf("error: %v", err)
-			p.outbound <- ServerError{
-				Message: err.Error(),
+			p.log.Debug("invalid message", "error", err)
+			p.send(serverError(nil, newError(ErrorInvalidMessage, err.Error())))
+			return
+		}
+
+		messagesIn.With(msg.ClientType()).Inc()
+
+		if err := checkMessage(msg, p.hub.config, limiter, time.Now()); err != nil {
+			p.send(serverError(msg, err))
+			if !limiter.violation(time.Now()) {
+				p.send(&closeConnection{CloseTooManyViolations, "too many rejected messages"})
+				return
 			}
+			continue
+		}
+
+		if hello, ok := msg.(ClientHello); ok {
+			if greeted {
+				p.send(serverError(msg, newError(ErrorAlreadyGreeted, "hello already received")))
+				continue
+			}
+			response, err := p.hello(hello)
+			if err != nil {
+				p.log.Debug("hello rejected", "error", err)
+				p.send(&closeConnection{CloseProtocolMismatch, err.Error()})
+				return
+			}
+			greeted = true
+			p.send(response)
+			continue
+		}
+
+		if !greeted {
+			p.send(&closeConnection{CloseHelloRequired, "expected hello"})
+			return
+		}
+
+		switch m := msg.(type) {
+		case ClientAck:
+			p.sent.ack(m.Seq)
+			continue
+		case ClientReconnect:
+			target, err := p.hub.reconnectTarget(p, m)
+			if err != nil {
+				p.send(serverError(msg, err))
+				continue
+			}
+			p.release()
+			target.attach(&reconnectRequest{
+				socket:       socket,
+				lastSeq:      m.LastSeq,
+				capabilities: p.capabilities,
+			})
+			handedOff = true
 			return
 		}
 
-		if p.room == nil {
-			HubMain.inbound <- &hubMessage{
+		if r := p.currentRoom(); r == nil {
+			p.hub.inbound <- &hubMessage{
 				clientMessage: msg,
 				player:        p,
 			}
 		} else {
-			p.room.inbound <- msg
+			r.post(msg)
 		}
 	}
 }
 
 // write pumps messages from the room to the websocket connection.
 //
-// A goroutine running write is started for each connection. The
-// application ensures that there is at most one writer to a connection by
-// executing all writes from this goroutine.
+// A goroutine running write is started for each player and outlives the
+// player's connections, so messages sent while the player is disconnected
+// are sequenced and buffered for replay. The application ensures that there
+// is at most one writer to a connection by executing all writes from this
+// goroutine.
 func (p *Player) write() {
-	ticker := time.NewTicker(pingPeriod)
+	ticker := time.NewTicker(p.hub.config.Timeouts.PingPeriod())
 	defer func() {
 		ticker.Stop()
-		p.socket.Close()
+		p.hub.removePlayer(p)
+		close(p.done)
+		p.log.Info("player gone")
 	}()
 	for {
 		select {
-		case message, ok := <-p.outbound:
-			p.socket.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				// room closed
-				p.socket.WriteMessage(websocket.CloseMessage, []byte{})
-				return
+		case <-p.outbound.ready:
+			for _, queued := range p.outbound.drain() {
+				if !p.handleOutbound(queued) {
+					return
+				}
 			}
-
-			fmt.Println("player->", message)
-
-			w, err := p.socket.NextWriter(websocket.TextMessage)
-			if err != nil {
-				log.Printf("error: %v\n", err)
-				return
+		case req := <-p.reconnect:
+			p.resume(req)
+		case socket := <-p.dropped:
+			if socket != p.socket {
+				// an old connection that was already replaced
+				socket.Close()
+				continue
 			}
-
-			s := structs.New(message)
-			s.TagName = "json"
-			m := s.Map()
-			m["type"] = message.ServerType()
-			m["room"] = p.room
-
-			if err := json.NewEncoder(w).Encode(m); err != nil {
-				return
-			}
-
-			if err := w.Close(); err != nil {
+			p.socket.Close()
+			p.setSocket(nil)
+			r := p.currentRoom()
+			if r == nil {
 				return
 			}
+			r.post(playerDisconnected{p})
+		case released := <-p.handoff:
+			p.setSocket(nil)
+			close(released)
+			return
 		case <-ticker.C:
-			p.socket.SetWriteDeadline(time.Now().Add(writeWait))
+			if p.socket == nil {
+				continue
+			}
+			p.socket.SetWriteDeadline(time.Now().Add(p.hub.config.Timeouts.Write))
 			if err := p.socket.WriteMessage(websocket.PingMessage, nil); err != nil {
-				return
+				// read will report the dropped connection
+				p.socket.Close()
 			}
 		}
 	}
 }
 
-func NewPlayer(socket *websocket.Conn) *Player {
+// handleOutbound processes a message taken from the player's queue. It returns
+// false if the write goroutine should exit.
+func (p *Player) handleOutbound(queued queuedMessage) bool {
+	switch m := queued.message.(type) {
+	case *closeConnection:
+		if p.socket != nil {
+			p.socket.SetWriteDeadline(time.Now().Add(p.hub.config.Timeouts.Write))
+			p.socket.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(m.code, m.reason))
+			p.socket.Close()
+		}
+		return true
+	case *messagesDropped:
+		// skip a sequence number so that the client cannot resume across the
+		// gap and gets a snapshot when it reconnects
+		p.seq++
+		p.sent.clear()
+		return true
+	case *playerGone:
+		p.state = nil
+		// if the player left the room while disconnected, there is nothing
+		// more to deliver
+		return p.socket != nil
+	}
+
+	p.deliver(queued.message, queued.state)
+	return true
+}
+
+// send queues a message for the player without room state. It never blocks;
+// if the queue is full, the overflow policy decides what happens.
+func (p *Player) send(message ServerMessage) {
+	p.outbound.push(message, nil)
+}
+
+// closeSocket closes the current connection from outside the write goroutine,
+// e.g. to disconnect a player who cannot keep up.
+func (p *Player) closeSocket() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.socket != nil {
+		p.socket.Close()
+	}
+}
+
+// hasSocket returns true if the player currently has a connection.
+func (p *Player) hasSocket() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.socket != nil
+}
+
+// setSocket replaces the current connection. It is only called by the write
+// goroutine.
+func (p *Player) setSocket(socket *websocket.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.socket = socket
+}
+
+// currentRoom returns the room the player is in, or nil. The room is read by
+// the player's goroutines and the hub while rooms change it.
+func (p *Player) currentRoom() *Room {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.room
+}
+
+// setRoom changes the room the player is in.
+func (p *Player) setRoom(r *Room) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.room = r
+}
+
+// deliver encodes a message with the room state it was sent with, buffers it
+// for replay and writes it to the current connection, if any.
+func (p *Player) deliver(message ServerMessage, state *stateUpdate) {
+	sent, err := p.encode(message, state)
+	if err != nil {
+		p.log.Error("failed to encode message", "type", message.ServerType(), "error", err)
+		return
+	}
+
+	messagesOut.With(message.ServerType()).Inc()
+	p.log.Debug("message sent", "type", message.ServerType(), "seq", sent.seq)
+	p.sent.add(sent)
+	p.writeSocket(sent)
+}
+
+// encode assigns the next sequence number to a message and encodes it with
+// the player's codec.
+func (p *Player) encode(message ServerMessage, state *stateUpdate) (sentMessage, error) {
+	s := structs.New(message)
+	s.TagName = "json"
+	m := s.Map()
+	p.seq++
+	m["type"] = message.ServerType()
+	m["seq"] = p.seq
+	_, snapshot := message.(*ServerSnapshot)
+	p.attachState(m, snapshot, state)
+
+	messageType, data, err := p.codec.encode(m)
+	if err != nil {
+		return sentMessage{}, err
+	}
+	return sentMessage{seq: p.seq, messageType: messageType, data: data}, nil
+}
+
+// writeSocket writes an encoded message to the current connection. On failure
+// the connection is closed and read reports it as dropped.
+func (p *Player) writeSocket(m sentMessage) {
+	if p.socket == nil {
+		return
+	}
+	p.socket.SetWriteDeadline(time.Now().Add(p.hub.config.Timeouts.Write))
+	if err := p.socket.WriteMessage(m.messageType, m.data); err != nil {
+		p.log.Debug("write failed", "error", err)
+		p.socket.Close()
+	}
+}
+
+// resume attaches a new connection to the player and replays the messages
+// the client missed, or sends a snapshot if they are no longer buffered.
+func (p *Player) resume(req *reconnectRequest) {
+	if p.socket != nil && p.socket != req.socket {
+		p.socket.Close()
+	}
+	p.setSocket(req.socket)
+	p.capabilities = req.capabilities
+
+	missed, ok := p.sent.since(req.lastSeq, p.seq)
+	if c := codecFor(req.socket); c != p.codec {
+		// buffered messages were encoded for another wire format
+		p.codec = c
+		missed, ok = nil, false
+	}
+	if ok {
+		for _, m := range missed {
+			p.writeSocket(m)
+		}
+	} else {
+		// the last state the player was sent; newer messages in the queue
+		// bring the client up to date
+		p.deliver(&ServerSnapshot{}, p.state)
+	}
+	p.deliver(&ServerReconnect{
+		Replayed: len(missed),
+		Snapshot: !ok,
+	}, nil)
+
+	go p.read(req.socket, true)
+	if r := p.currentRoom(); r != nil {
+		r.post(playerReconnected{p})
+	}
+}
+
+// release stops the player's write goroutine without closing its connection,
+// so that the connection can be attached to another player.
+func (p *Player) release() {
+	released := make(chan struct{})
+	p.handoff <- released
+	<-released
+}
+
+// attach hands a connection over to the player.
+func (p *Player) attach(req *reconnectRequest) {
+	select {
+	case p.reconnect <- req:
+	case <-p.done:
+		req.socket.Close()
+	}
+}
+
+// attachState adds the room state a message was sent with. Snapshots carry
+// the full state in "room". Clients that announced the "delta" capability
+// also get a patch against the previous version whenever the state changed,
+// or the full state if they missed a version sent only to others. "version"
+// lets clients detect gaps.
+func (p *Player) attachState(m map[string]any, snapshot bool, state *stateUpdate) {
+	if snapshot && state != nil {
+		p.state = state
+		m["room"] = state.state
+		m["version"] = state.version
+		return
+	}
+	if p.state == nil || !slices.Contains(p.capabilities, "delta") {
+		return
+	}
+
+	if state != nil && state.version > p.state.version {
+		if state.version == p.state.version+1 && state.patch != nil {
+			m["patch"] = state.patch
+		} else {
+			m["room"] = state.state
+		}
+		p.state = state
+	}
+	m["version"] = p.state.version
+}
+
+// remoteHost returns the host part of the remote address of the socket.
+func remoteHost(socket *websocket.Conn) string {
+	addr := socket.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// NewPlayer creates a player for a new connection and starts its read and
+// write goroutines.
+func (h *Hub) NewPlayer(socket *websocket.Conn) *Player {
 	p := &Player{
-		Id:       util.IdFrom("p", socket.RemoteAddr().String()),
-		Name:     strings.Join(words.Words(words.English, 2), " "),
-		socket:   socket,
-		Hand:     PlayerHand{},
-		outbound: make(chan ServerMessage),
+		Id:        util.IdFrom("p", socket.RemoteAddr().String()),
+		Name:      strings.Join(words.Words(words.English, 2), " "),
+		Connected: true,
+		hub:       h,
+		socket:    socket,
+		codec:     codecFor(socket),
+		Hand:      PlayerHand{},
+		outbound:  newSendQueue(h.config.Queue),
+		session:   util.SessionToken(),
+		address:   util.LongIdFrom("a", remoteHost(socket)),
+		sent:      newResendBuffer(resendBufferSize),
+		reconnect: make(chan *reconnectRequest),
+		dropped:   make(chan *websocket.Conn),
+		handoff:   make(chan chan struct{}),
+		done:      make(chan struct{}),
 	}
 
-	go p.read()
+	p.log = h.Log.With("player", p.Id)
+	p.outbound.overflow = p.closeSocket
+	h.addPlayer(p)
+	p.log.Info("player connected")
+
+	go p.read(socket, false)
 	go p.write()
 
 	return p
@@ -0,0 +1,50 @@
+package game
+
+import (
+	"cardgame/build"
+	"fmt"
+)
+
+const (
+	// ProtocolVersion is the version of the websocket protocol spoken by the server.
+	// It must be incremented whenever a change breaks existing clients.
+	ProtocolVersion = 1
+
+	// MinProtocolVersion is the oldest protocol version the server still accepts.
+	MinProtocolVersion = 1
+)
+
+// Websocket close codes used by the server, in the range reserved for applications.
+const (
+	// CloseProtocolMismatch is sent when the client's protocol version is not supported.
+	CloseProtocolMismatch = 4001
+	// CloseHelloRequired is sent when a client sends a message before hello.
+	CloseHelloRequired = 4002
+	// CloseTooManyViolations is sent when a client keeps sending invalid or
+	// rate limited messages.
+	CloseTooManyViolations = 4003
+)
+
+// Features are the optional protocol features supported by the server.
+var Features = []string{
+	"delta",   // state patches with versions, ClientRequestSnapshot
+	"resume",  // sequence numbers, ClientAck and ClientReconnect
+	"msgpack", // binary wire format, negotiated with the cardgame.msgpack subprotocol
+}
+
+// hello validates the client's hello and returns the server's response.
+func (p *Player) hello(msg ClientHello) (*ServerHello, error) {
+	if msg.ProtocolVersion < MinProtocolVersion || msg.ProtocolVersion > ProtocolVersion {
+		return nil, fmt.Errorf("unsupported protocol version %d, server supports %d to %d",
+			msg.ProtocolVersion, MinProtocolVersion, ProtocolVersion)
+	}
+
+	p.capabilities = msg.Capabilities
+
+	return &ServerHello{
+		ProtocolVersion: ProtocolVersion,
+		Version:         build.Version(),
+		Commit:          build.Commit(),
+		Features:        Features,
+	}, nil
+}
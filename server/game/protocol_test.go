@@ -0,0 +1,27 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHello(t *testing.T) {
+	p := &Player{}
+	response, err := p.hello(ClientHello{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    []string{"delta"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, ProtocolVersion, response.ProtocolVersion)
+	assert.Equal(t, Features, response.Features)
+	assert.Equal(t, []string{"delta"}, p.capabilities)
+}
+
+func TestHelloIncompatible(t *testing.T) {
+	p := &Player{}
+	for _, version := range []int{0, MinProtocolVersion - 1, ProtocolVersion + 1} {
+		_, err := p.hello(ClientHello{ProtocolVersion: version})
+		assert.Error(t, err, "version %d should be rejected", version)
+	}
+}
@@ -0,0 +1,165 @@
+package game
+
+import (
+	"cardgame/config"
+	"sync"
+)
+
+// queuedMessage is a message waiting in a player's queue, with the room state
+// it was sent with, if any.
+type queuedMessage struct {
+	message ServerMessage
+	state   *stateUpdate
+}
+
+// sendQueue is a bounded, non-blocking queue of messages waiting to be
+// written to a player, so that one slow connection cannot block the room.
+type sendQueue struct {
+	mu          sync.Mutex
+	config      config.QueueConfig
+	messages    []queuedMessage
+	maxDepth    int           // highest number of queued messages seen
+	overflowing bool          // true once overflow was called, until the queue is drained
+	ready       chan struct{} // signalled when messages are added
+
+	// overflow is called when a message is queued beyond the size limit, once
+	// until the queue is drained, and whenever the queue is discarded. The
+	// message is kept, so it can still be replayed after a reconnect.
+	overflow func()
+}
+
+func newSendQueue(config config.QueueConfig) *sendQueue {
+	return &sendQueue{
+		config: config,
+		ready:  make(chan struct{}, 1),
+	}
+}
+
+// isCritical returns false for messages that can be dropped without breaking
+// the game, because later messages supersede them.
+func isCritical(message ServerMessage) bool {
+	switch message.(type) {
+	case *ServerChat, *ServerResync, *ServerPresence:
+		return false
+	}
+	return true
+}
+
+// isControl returns true for internal messages that are never dropped.
+func isControl(message ServerMessage) bool {
+	switch message.(type) {
+	case *closeConnection, *playerGone, *messagesDropped:
+		return true
+	}
+	return false
+}
+
+func (q *sendQueue) push(message ServerMessage, state *stateUpdate) {
+	q.mu.Lock()
+
+	overflowed := false
+	if _, ok := message.(*ServerResync); ok && q.config.Coalesce {
+		for i, m := range q.messages {
+			if _, ok := m.message.(*ServerResync); ok {
+				q.messages = append(q.messages[:i], q.messages[i+1:]...)
+				queueCoalesced.Inc()
+				break
+			}
+		}
+	}
+
+	switch {
+	case isControl(message):
+	case len(q.messages) >= q.config.MaxSize:
+		// even the critical messages pile up, so the client is too far
+		// behind to catch up message by message
+		q.discard()
+		overflowed = true
+		q.overflowing = true
+		queueOverflows.Inc()
+	case len(q.messages) >= q.config.Size:
+		if q.config.Overflow == config.OverflowDrop && !isCritical(message) {
+			q.mu.Unlock()
+			queueDropped.Inc()
+			return
+		}
+		if !q.overflowing {
+			overflowed = true
+			q.overflowing = true
+			queueOverflows.Inc()
+		}
+	}
+
+	q.messages = append(q.messages, queuedMessage{message, state})
+	if len(q.messages) > q.maxDepth {
+		q.maxDepth = len(q.messages)
+	}
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+
+	if overflowed && q.overflow != nil {
+		q.overflow()
+	}
+}
+
+// discard drops every queued message except control messages and queues
+// messagesDropped in their place. q.mu must be held.
+func (q *sendQueue) discard() {
+	kept := []queuedMessage{}
+	for _, m := range q.messages {
+		if isControl(m.message) {
+			kept = append(kept, m)
+		}
+	}
+	queueDropped.Add(float64(len(q.messages) - len(kept)))
+	q.messages = append(kept, queuedMessage{message: &messagesDropped{}})
+}
+
+// drain removes and returns all queued messages.
+func (q *sendQueue) drain() []queuedMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	messages := q.messages
+	q.messages = nil
+	q.overflowing = false
+	return messages
+}
+
+// depth returns the number of queued messages and the highest number seen.
+func (q *sendQueue) depth() (current, max int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.messages), q.maxDepth
+}
+
+// QueueStatistics describes the outbound queues of all players.
+type QueueStatistics struct {
+	Players   int   `json:"players"`   // number of connected players, in rooms or not
+	Queued    int   `json:"queued"`    // messages currently queued
+	MaxDepth  int   `json:"maxDepth"`  // highest queue depth seen by any player
+	Dropped   int64 `json:"dropped"`   // messages dropped, non-critical or discarded from a full queue
+	Coalesced int64 `json:"coalesced"` // resyncs replaced by newer ones
+	Overflows int64 `json:"overflows"` // times a player was disconnected for falling behind
+}
+
+// QueueStats returns statistics about the players' outbound queues.
+func (h *Hub) QueueStats() QueueStatistics {
+	stats := QueueStatistics{
+		Dropped:   int64(queueDropped.Value()),
+		Coalesced: int64(queueCoalesced.Value()),
+		Overflows: int64(queueOverflows.Value()),
+	}
+	for _, p := range h.Players() {
+		current, max := p.outbound.depth()
+		stats.Players++
+		stats.Queued += current
+		if max > stats.MaxDepth {
+			stats.MaxDepth = max
+		}
+	}
+	return stats
+}
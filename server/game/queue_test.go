@@ -0,0 +1,140 @@
+package game
+
+import (
+	"cardgame/config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestPlayer returns a player without a connection or write goroutine.
+// Messages sent to it stay in its queue until drained by the test.
+func newTestPlayer(id string) *Player {
+	return &Player{
+		Id:        id,
+		session:   "s_" + id,
+		address:   "a_" + id,
+		Hand:      PlayerHand{},
+		Connected: true,
+		outbound:  newSendQueue(HubMain.config.Queue),
+		sent:      newResendBuffer(resendBufferSize),
+		done:      make(chan struct{}),
+	}
+}
+
+func TestQueueDropNonCritical(t *testing.T) {
+	q := newSendQueue(config.QueueConfig{Size: 2, MaxSize: 10, Overflow: config.OverflowDrop})
+	overflows := 0
+	q.overflow = func() { overflows++ }
+
+	q.push(&ServerDraw{}, nil)
+	q.push(&ServerDraw{}, nil)
+	q.push(&ServerChat{Message: "dropped"}, nil)
+	assert.Equal(t, 0, overflows, "chat should be dropped silently")
+
+	q.push(&ServerTurn{}, nil)
+	assert.Equal(t, 1, overflows, "critical message should overflow")
+
+	q.push(&playerGone{}, nil)
+	assert.Equal(t, 1, overflows, "control messages are always queued")
+
+	q.push(&ServerTurn{}, nil)
+	assert.Equal(t, 1, overflows, "overflow is reported once until the queue is drained")
+
+	messages := q.drain()
+	assert.Len(t, messages, 5)
+	assert.IsType(t, &ServerTurn{}, messages[2].message)
+
+	current, max := q.depth()
+	assert.Equal(t, 0, current)
+	assert.Equal(t, 5, max)
+
+	q.push(&ServerDraw{}, nil)
+	q.push(&ServerDraw{}, nil)
+	q.push(&ServerTurn{}, nil)
+	assert.Equal(t, 2, overflows)
+}
+
+func TestQueueDiscard(t *testing.T) {
+	q := newSendQueue(config.QueueConfig{Size: 1, MaxSize: 3, Overflow: config.OverflowDrop})
+	overflows := 0
+	q.overflow = func() { overflows++ }
+
+	q.push(&ServerDraw{}, nil)
+	q.push(&ServerDraw{}, nil)
+	q.push(&playerGone{}, nil)
+	assert.Equal(t, 1, overflows)
+	q.push(&ServerTurn{}, nil)
+	assert.Equal(t, 2, overflows, "exceeding the hard limit disconnects again")
+
+	messages := q.drain()
+	assert.Len(t, messages, 3, "critical messages are discarded")
+	assert.IsType(t, &playerGone{}, messages[0].message)
+	assert.IsType(t, &messagesDropped{}, messages[1].message)
+	assert.IsType(t, &ServerTurn{}, messages[2].message)
+
+	// the client cannot resume across the discarded messages
+	p := newTestPlayer("p_slow")
+	p.seq = 1
+	p.sent.add(sentMessage{seq: 1})
+	assert.True(t, p.handleOutbound(messages[1]))
+	_, ok := p.sent.since(1, p.seq)
+	assert.False(t, ok)
+}
+
+func TestQueueDisconnect(t *testing.T) {
+	q := newSendQueue(config.QueueConfig{Size: 1, MaxSize: 10, Overflow: config.OverflowDisconnect})
+	overflows := 0
+	q.overflow = func() { overflows++ }
+
+	q.push(&ServerChat{}, nil)
+	q.push(&ServerChat{}, nil)
+	assert.Equal(t, 1, overflows)
+}
+
+func TestQueueCoalesce(t *testing.T) {
+	q := newSendQueue(config.QueueConfig{Size: 10, MaxSize: 10, Coalesce: true})
+	first := &ServerResync{}
+	last := &ServerResync{}
+
+	q.push(first, nil)
+	q.push(&ServerDraw{}, nil)
+	q.push(last, nil)
+
+	messages := q.drain()
+	assert.Len(t, messages, 2)
+	assert.IsType(t, &ServerDraw{}, messages[0].message)
+	assert.Same(t, last, messages[1].message)
+}
+
+func TestBlockedPlayerDoesNotStallRoom(t *testing.T) {
+	blocked := newTestPlayer("p_blocked")
+	disconnected := false
+	blocked.outbound.overflow = func() { disconnected = true }
+	active := newTestPlayer("p_active")
+	r := newTestRoom(t, blocked, active)
+
+	const count = 1000
+	received := make(chan int)
+	go func() {
+		n := 0
+		for n < count {
+			select {
+			case <-active.outbound.ready:
+				n += len(active.outbound.drain())
+			case <-time.After(2 * time.Second):
+				received <- n
+				return
+			}
+		}
+		received <- n
+	}()
+
+	for i := 0; i < count; i++ {
+		r.broadcast(&serverPayload{message: &ServerDraw{}})
+	}
+
+	assert.Equal(t, count, <-received, "active player should receive every message")
+	assert.True(t, disconnected, "blocked player should be disconnected")
+}
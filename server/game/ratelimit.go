@@ -0,0 +1,72 @@
+package game
+
+import (
+	"cardgame/config"
+	"time"
+)
+
+// tokenBucket implements a rate limit.
+type tokenBucket struct {
+	limit  config.RateLimit
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit config.RateLimit, now time.Time) *tokenBucket {
+	return &tokenBucket{limit: limit, tokens: limit.Burst, last: now}
+}
+
+// allow takes a token from the bucket, if there is one.
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+	if b.tokens > b.limit.Burst {
+		b.tokens = b.limit.Burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// rateLimiter limits the messages of a single connection, both in total and
+// per message type. It is only used by the connection's read goroutine.
+type rateLimiter struct {
+	limits     config.RateLimitConfig
+	total      *tokenBucket
+	perType    map[string]*tokenBucket
+	violations *tokenBucket
+}
+
+func newRateLimiter(limits config.RateLimitConfig, now time.Time) *rateLimiter {
+	return &rateLimiter{
+		limits:     limits,
+		total:      newTokenBucket(limits.Total, now),
+		perType:    make(map[string]*tokenBucket),
+		violations: newTokenBucket(limits.Violations, now),
+	}
+}
+
+// allow returns true if a message of the given type may be handled now.
+func (l *rateLimiter) allow(messageType string, now time.Time) bool {
+	bucket, ok := l.perType[messageType]
+	if !ok {
+		limit, ok := l.limits.PerType[messageType]
+		if !ok {
+			limit = l.limits.Default
+		}
+		bucket = newTokenBucket(limit, now)
+		l.perType[messageType] = bucket
+	}
+
+	// check the type first so spamming one type does not use up the total
+	return bucket.allow(now) && l.total.allow(now)
+}
+
+// violation records a rejected message and returns false once the
+// connection has exceeded the violation limit and should be closed.
+func (l *rateLimiter) violation(now time.Time) bool {
+	return l.violations.allow(now)
+}
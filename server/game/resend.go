@@ -0,0 +1,73 @@
+package game
+
+import "sync"
+
+// sentMessage is an encoded message kept for replay after a reconnect.
+type sentMessage struct {
+	seq         int
+	messageType int
+	data        []byte
+}
+
+// resendBuffer holds the most recent unacknowledged messages sent to a player.
+type resendBuffer struct {
+	mu       sync.Mutex
+	size     int
+	messages []sentMessage
+}
+
+func newResendBuffer(size int) *resendBuffer {
+	return &resendBuffer{size: size}
+}
+
+// add appends a message, evicting the oldest one if the buffer is full.
+func (b *resendBuffer) add(m sentMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.messages = append(b.messages, m)
+	if len(b.messages) > b.size {
+		b.messages = b.messages[len(b.messages)-b.size:]
+	}
+}
+
+// ack discards all messages up to and including seq.
+func (b *resendBuffer) ack(seq int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	i := 0
+	for i < len(b.messages) && b.messages[i].seq <= seq {
+		i++
+	}
+	b.messages = b.messages[i:]
+}
+
+// clear discards all messages.
+func (b *resendBuffer) clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages = nil
+}
+
+// since returns the messages sent after seq, where current is the sequence
+// number of the last message sent. ok is false if some of the missed
+// messages are no longer in the buffer.
+func (b *resendBuffer) since(seq int, current int) (missed []sentMessage, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if seq >= current {
+		return nil, seq == current
+	}
+	if len(b.messages) == 0 || b.messages[0].seq > seq+1 {
+		return nil, false
+	}
+
+	for _, m := range b.messages {
+		if m.seq > seq {
+			missed = append(missed, m)
+		}
+	}
+	return missed, true
+}
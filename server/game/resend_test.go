@@ -0,0 +1,55 @@
+package game
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fillResendBuffer(b *resendBuffer, from, to int) {
+	for seq := from; seq <= to; seq++ {
+		b.add(sentMessage{seq: seq})
+	}
+}
+
+func TestResendBufferSince(t *testing.T) {
+	b := newResendBuffer(10)
+	fillResendBuffer(b, 1, 5)
+
+	missed, ok := b.since(2, 5)
+	assert.True(t, ok)
+	assert.Len(t, missed, 3)
+	assert.Equal(t, 3, missed[0].seq)
+
+	missed, ok = b.since(5, 5)
+	assert.True(t, ok)
+	assert.Empty(t, missed)
+
+	_, ok = b.since(7, 5)
+	assert.False(t, ok, "client cannot be ahead of the server")
+}
+
+func TestResendBufferEviction(t *testing.T) {
+	b := newResendBuffer(4)
+	fillResendBuffer(b, 1, 10)
+
+	_, ok := b.since(5, 10)
+	assert.False(t, ok, "message 6 should have been evicted")
+
+	missed, ok := b.since(6, 10)
+	assert.True(t, ok)
+	assert.Len(t, missed, 4)
+}
+
+func TestResendBufferAck(t *testing.T) {
+	b := newResendBuffer(10)
+	fillResendBuffer(b, 1, 5)
+	b.ack(3)
+
+	missed, ok := b.since(3, 5)
+	assert.True(t, ok)
+	assert.Len(t, missed, 2)
+
+	_, ok = b.since(1, 5)
+	assert.False(t, ok, "acknowledged messages are not replayed")
+}
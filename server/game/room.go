@@ -3,8 +3,10 @@ package game
 import (
 	"cardgame/card"
 	"cardgame/deck"
+	"cardgame/util/logging"
 	"cardgame/util/slices"
 	"fmt"
+	"sync"
 )
 
 // Room represents a game room.
@@ -16,6 +18,7 @@ type Room struct {
 	MaxPlayers     int              `json:"maxPlayers"`     // maximum number of players
 	OwnerId        string           `json:"ownerId"`        // owner's player id
 	Players        []*Player        `json:"players"`        // players in the room, including the owner
+	Spectators     []*Player        `json:"spectators"`     // players waiting for the next game
 	Decks          []*deck.Deck     `json:"decks"`          // decks in use
 	PlayMode       PlayMode         `json:"playMode"`       // play mode
 	HubDeviceId    string           `json:"hubDeviceId"`    // hub device id
@@ -23,15 +26,35 @@ type Room struct {
 	GamePhase      GamePhase        `json:"gamePhase"`      // game phase
 	ActiveWildCard *card.WildCard   `json:"activeWildCard"` // active wild card
 	DrawPileSize   int              `json:"drawPileSize"`   // size of the draw pile
+	GameStartedAt  int64            `json:"gameStartedAt"`  // unix milliseconds, moved forward by the length of each pause
+	TurnStartedAt  int64            `json:"turnStartedAt"`  // unix milliseconds, moved forward by the length of each pause
+	Paused         bool             `json:"paused"`         // true while the game is paused
+	PausedAt       int64            `json:"pausedAt"`       // unix milliseconds, 0 if not paused
+	SlowMode       int              `json:"slowMode"`       // minimum seconds between chat messages of a player
+	LeaveCards     LeaveCards       `json:"leaveCards"`     // what happens to the cards of a player leaving mid-game
+	LateJoin       bool             `json:"lateJoin"`       // whether players joining mid-game take part instead of spectating
+	AutoStart      int              `json:"autoStart"`      // seconds until the game starts once everyone is ready, 0 to disable
 	drawPile       []card.BaseCard  // draw pile
 	usedWildCards  []*card.WildCard // already used wild cards
+	chatHistory    []*ServerChat    // recent public chat messages, oldest first
+	chatCounter    int              // number of chat messages sent, for ids
+	joinCounter    int              // number of players that joined, for Player.joined
+	bans           set              // identities banned from the room
+	kickVotes      map[string]set   // player id -> ids of players voting to kick them
+	countdown      int              // incremented whenever an auto-start countdown starts or is cancelled
+	counting       bool             // true while an auto-start countdown is running
+	autoPaused     bool             // true if the game was paused because the current player disconnected
+	state          *stateUpdate     // last published room state
 
 	private      bool   // true if the room is private
 	passwordHash string // password hash for private rooms
 
-	hub      *Hub                // hub instance
-	inbound  chan ClientMessage  // incoming client messages
-	outbound chan *serverPayload // outgoing server messages
+	mu      sync.Mutex         // serializes message handling
+	hub     *Hub               // hub instance
+	inbound chan ClientMessage // incoming client messages
+	done    chan struct{}      // closed when the room is closed
+	closed  bool               // true once the room is closed, guarded by mu
+	log     *logging.Logger    // logger with the room id
 }
 
 func (r *Room) getPlayer(id string) *Player {
@@ -43,6 +66,29 @@ func (r *Room) getPlayer(id string) *Player {
 	return nil
 }
 
+// members returns the players and spectators in the room.
+func (r *Room) members() []*Player {
+	return append(append([]*Player{}, r.Players...), r.Spectators...)
+}
+
+// getMember returns the player or spectator with the given id.
+func (r *Room) getMember(id string) *Player {
+	for _, p := range r.members() {
+		if p.Id == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// promoteSpectators moves spectators into the game while there is room.
+func (r *Room) promoteSpectators() {
+	for len(r.Spectators) > 0 && !r.IsFull() {
+		r.Players = append(r.Players, r.Spectators[0])
+		r.Spectators = r.Spectators[1:]
+	}
+}
+
 func (r *Room) createDrawPile() {
 	r.drawPile = []card.BaseCard{}
 	for _, d := range r.Decks {
@@ -90,6 +136,7 @@ func (r *Room) recreateDrawPile() error {
 	}
 	slices.Shuffle(newDrawPile)
 	r.drawPile = newDrawPile
+	r.DrawPileSize = len(r.drawPile)
 
 	// choose new wild card
 	r.usedWildCards = append(r.usedWildCards, r.ActiveWildCard)
@@ -99,6 +146,28 @@ func (r *Room) recreateDrawPile() error {
 	return nil
 }
 
+// takeWildCard removes a wild card from the draw pile, or returns nil if it
+// has none.
+func (r *Room) takeWildCard() *card.WildCard {
+	for i, c := range r.drawPile {
+		if wild, ok := c.(*card.WildCard); ok {
+			r.drawPile = append(r.drawPile[:i:i], r.drawPile[i+1:]...)
+			r.DrawPileSize = len(r.drawPile)
+			return wild
+		}
+	}
+	return nil
+}
+
+// returnCards puts cards of a player leaving the game back into the draw pile.
+func (r *Room) returnCards(hand PlayerHand) {
+	for _, c := range hand {
+		r.drawPile = append(r.drawPile, c)
+	}
+	slices.Shuffle(r.drawPile)
+	r.DrawPileSize = len(r.drawPile)
+}
+
 func (r *Room) resync() {
 	topCards := make(map[string]*card.Card)
 
@@ -106,22 +175,35 @@ func (r *Room) resync() {
 		topCards[p.Id] = p.Hand.top()
 	}
 
-	r.outbound <- &serverPayload{
+	r.broadcast(&serverPayload{
 		message: &ServerResync{
 			TopCards: topCards,
 		},
-	}
+	})
 
 }
 
 // IsPrivate returns true if the room is private.
 func (r *Room) IsPrivate() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	return r.private
 }
 
 // CheckPassword returns true if the password is correct.
 func (r *Room) CheckPassword(password string) bool {
-	return checkPassword(r.passwordHash, password)
+	r.mu.Lock()
+	hash := r.passwordHash
+	r.mu.Unlock()
+	return checkPassword(hash, password)
+}
+
+// State returns the room as sent to players, taken under the room lock so it
+// can be serialized safely while the room changes.
+func (r *Room) State() (any, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return roomState(r)
 }
 
 // IsFull returns true if the room is full.
@@ -141,55 +223,81 @@ func (r *Room) SetPassword(password string) {
 	}
 }
 
-func (r *Room) read() {
-	for {
-		message, ok := <-r.inbound
-		if !ok {
-			fmt.Println("room<- closed")
-			// room closed
-			return
-		}
-		go r.HandleMessage(message)
+// post queues a message for the room. Messages for a closed room are dropped.
+func (r *Room) post(message ClientMessage) {
+	select {
+	case r.inbound <- message:
+	case <-r.done:
+	}
+}
+
+// close removes every player from the room and stops it. Players are told
+// why with a ServerRoomClosed message and can join another room.
+func (r *Room) close(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+
+	r.closed = true
+	r.log.Info("room closed", "reason", reason)
+	for _, p := range r.members() {
+		p.send(&ServerRoomClosed{
+			Reason: reason,
+		})
+		p.setRoom(nil)
+		p.send(&playerGone{})
 	}
+	r.Players = []*Player{}
+	r.Spectators = []*Player{}
+	close(r.done)
 }
 
-func (r *Room) write() {
+func (r *Room) read() {
 	for {
-		payload, ok := <-r.outbound
-		if !ok {
-			fmt.Println("room-> closed")
-			// room closed
+		select {
+		case message := <-r.inbound:
+			go r.HandleMessage(message)
+		case <-r.done:
 			return
 		}
+	}
+}
 
-		included := []*Player{}
-		other := []*Player{}
-
-		for _, p := range r.Players {
+// broadcast sends a message to the members of the room selected by the
+// payload: the included members if any, or everyone not excluded. Recipients
+// and the room state attached to the message are determined once, when the
+// message is sent, so it must be called with r.mu held.
+func (r *Room) broadcast(payload *serverPayload) {
+	var recipients []*Player
+	if len(payload.include) > 0 {
+		for _, p := range r.members() {
 			if _, ok := payload.include[p.Id]; ok {
-				included = append(included, p)
-				continue
+				recipients = append(recipients, p)
 			}
-			if _, ok := payload.exclude[p.Id]; ok {
-				continue
+		}
+	} else {
+		for _, p := range r.members() {
+			if _, ok := payload.exclude[p.Id]; !ok {
+				recipients = append(recipients, p)
 			}
-
-			other = append(other, p)
 		}
+	}
 
-		var toSend []*Player
-		if len(included) > 0 {
-			toSend = included
-		} else if len(other) > 0 {
-			toSend = other
-		} else {
-			continue
-		}
+	if len(recipients) == 0 {
+		return
+	}
 
-		fmt.Println("room->", payload.message)
-		for _, p := range toSend {
-			fmt.Println("room->    sending to", p.Id)
-			p.outbound <- payload.message
-		}
+	state := r.publishState()
+	r.log.Debug("broadcast", "type", payload.message.ServerType(), "recipients", len(recipients))
+	for _, p := range recipients {
+		p.outbound.push(payload.message, state)
 	}
 }
+
+// send sends a message to a single member along with the current room state.
+// It must be called with r.mu held.
+func (r *Room) send(p *Player, message ServerMessage) {
+	p.outbound.push(message, r.publishState())
+}
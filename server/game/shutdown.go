@@ -0,0 +1,95 @@
+package game
+
+import (
+	"context"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// shutdownPoll is how often Shutdown checks whether all connections are closed.
+const shutdownPoll = 50 * time.Millisecond
+
+// ReconnectAfter is how long clients are asked to wait before reconnecting
+// after a shutdown, to give a replacement server time to start.
+var ReconnectAfter = 5 * time.Second
+
+// Draining returns true once the hub is shutting down. A draining hub does
+// not accept new rooms, connections or joins.
+func (h *Hub) Draining() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.draining
+}
+
+// Shutdown stops the hub from accepting new rooms and players, sends every
+// connected player a ServerShutdown and closes their connections. It returns
+// once all connections are closed, or with the context's error after
+// force-closing the remaining connections when the context is done.
+func (h *Hub) Shutdown(ctx context.Context, reason string) error {
+	h.mu.Lock()
+	h.draining = true
+	h.mu.Unlock()
+
+	players := h.Players()
+	h.Log.Info("shutting down", "reason", reason, "players", len(players))
+
+	shutdown := &ServerShutdown{
+		Reason:         reason,
+		ReconnectAfter: int(ReconnectAfter.Milliseconds()),
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		shutdown.Deadline = deadline.UnixMilli()
+	}
+	for _, p := range players {
+		p.send(shutdown)
+		p.send(&closeConnection{websocket.CloseGoingAway, reason})
+	}
+
+	ticker := time.NewTicker(shutdownPoll)
+	defer ticker.Stop()
+	for {
+		open := 0
+		for _, p := range players {
+			if p.hasSocket() {
+				open++
+			}
+		}
+		if open == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			h.Log.Warn("closing remaining connections", "open", open)
+			for _, p := range players {
+				p.closeSocket()
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// HubSnapshot is the state of every room at the time of a shutdown.
+type HubSnapshot struct {
+	Timestamp int64             `json:"timestamp"` // unix milliseconds
+	Rooms     []*RoomInspection `json:"rooms"`
+}
+
+// Snapshot returns the full state of every room, for debugging or later
+// inspection after a shutdown.
+func (h *Hub) Snapshot() (*HubSnapshot, error) {
+	s := &HubSnapshot{
+		Timestamp: time.Now().UnixMilli(),
+		Rooms:     []*RoomInspection{},
+	}
+	for _, r := range h.AllRooms() {
+		inspection, err := r.Inspect()
+		if err != nil {
+			return nil, err
+		}
+		s.Rooms = append(s.Rooms, inspection)
+	}
+	return s, nil
+}
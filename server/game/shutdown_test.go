@@ -0,0 +1,36 @@
+package game
+
+import (
+	"cardgame/config"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdown(t *testing.T) {
+	h := NewHub(config.Default(), nil)
+	r := h.NewRoom("")
+	t.Cleanup(func() { r.close("test") })
+
+	p := newTestPlayer("p_1")
+	h.addPlayer(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, h.Shutdown(ctx, "maintenance"))
+	assert.True(t, h.Draining())
+
+	shutdown := receive[*ServerShutdown](t, p)
+	assert.Equal(t, "maintenance", shutdown.Reason)
+	assert.NotZero(t, shutdown.Deadline)
+	assert.Equal(t, int(ReconnectAfter.Milliseconds()), shutdown.ReconnectAfter)
+
+	h.handleJoin(ClientJoin{Player: p, RoomId: r.Id})
+	assert.Equal(t, ErrorShuttingDown, receive[*ServerError](t, p).Code)
+
+	snapshot, err := h.Snapshot()
+	assert.NoError(t, err)
+	assert.Len(t, snapshot.Rooms, 1)
+}
@@ -0,0 +1,262 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PatchOp is a single JSON Patch (RFC 6902) operation. Only add, remove and
+// replace are produced by the server.
+type PatchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value,omitempty" ts_type:"any"` // omitted only for remove
+}
+
+// MarshalJSON always includes the value of add and replace operations, even
+// if it is null, as RFC 6902 requires.
+func (op PatchOp) MarshalJSON() ([]byte, error) {
+	if op.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{op.Op, op.Path})
+	}
+	return json.Marshal(struct {
+		Op    string `json:"op"`
+		Path  string `json:"path"`
+		Value any    `json:"value"`
+	}{op.Op, op.Path, op.Value})
+}
+
+// Patch is a list of operations that transforms one room state into the next.
+type Patch []PatchOp
+
+// roomState returns the JSON representation of the room as generic values,
+// which is what the clients see and what patches are computed against.
+func roomState(r *Room) (any, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	var state any
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// stateUpdate is a version of the room state. It is computed once under the
+// room lock and shared by every message sent with it.
+type stateUpdate struct {
+	version int   // incremented whenever the state changes
+	state   any   // room state as generic JSON values
+	patch   Patch // turns the previous version into this one, nil for the first
+}
+
+// publishState returns the current room state, diffed against the previous
+// version if anything changed. It must be called with r.mu held.
+func (r *Room) publishState() *stateUpdate {
+	state, err := roomState(r)
+	if err != nil {
+		r.log.Error("failed to serialize room state", "error", err)
+		return r.state
+	}
+
+	if r.state == nil {
+		r.state = &stateUpdate{version: 1, state: state}
+	} else if patch := diffState("", r.state.state, state, nil); len(patch) > 0 {
+		r.state = &stateUpdate{version: r.state.version + 1, state: state, patch: patch}
+	}
+	return r.state
+}
+
+// escapePointer escapes a key for use in a JSON pointer.
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+// unescapePointer reverses escapePointer.
+func unescapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+}
+
+// diffState appends the operations needed to turn a into b to the patch.
+// a and b must be generic JSON values (maps, slices and scalars).
+func diffState(path string, a, b any, patch Patch) Patch {
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok {
+			return append(patch, PatchOp{Op: "replace", Path: path, Value: b})
+		}
+
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			childPath := path + "/" + escapePointer(k)
+			oldValue, inA := av[k]
+			newValue, inB := bv[k]
+			switch {
+			case !inB:
+				patch = append(patch, PatchOp{Op: "remove", Path: childPath})
+			case !inA:
+				patch = append(patch, PatchOp{Op: "add", Path: childPath, Value: newValue})
+			default:
+				patch = diffState(childPath, oldValue, newValue, patch)
+			}
+		}
+		return patch
+	case []any:
+		bv, ok := b.([]any)
+		if !ok {
+			return append(patch, PatchOp{Op: "replace", Path: path, Value: b})
+		}
+
+		common := len(av)
+		if len(bv) < common {
+			common = len(bv)
+		}
+		for i := 0; i < common; i++ {
+			patch = diffState(path+"/"+strconv.Itoa(i), av[i], bv[i], patch)
+		}
+		// remove from the end so earlier indices stay valid
+		for i := len(av) - 1; i >= len(bv); i-- {
+			patch = append(patch, PatchOp{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+		}
+		for i := len(av); i < len(bv); i++ {
+			patch = append(patch, PatchOp{Op: "add", Path: path + "/" + strconv.Itoa(i), Value: bv[i]})
+		}
+		return patch
+	default:
+		if !reflect.DeepEqual(a, b) {
+			return append(patch, PatchOp{Op: "replace", Path: path, Value: b})
+		}
+		return patch
+	}
+}
+
+// ApplyPatch applies the patch to a generic JSON document and returns the
+// result. The document may be modified in place.
+func ApplyPatch(doc any, patch Patch) (any, error) {
+	for _, op := range patch {
+		var tokens []string
+		if op.Path != "" {
+			if op.Path[0] != '/' {
+				return nil, fmt.Errorf("invalid patch path %q", op.Path)
+			}
+			tokens = strings.Split(op.Path[1:], "/")
+			for i, t := range tokens {
+				tokens[i] = unescapePointer(t)
+			}
+		}
+
+		var err error
+		doc, err = applyOp(doc, tokens, op)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func applyOp(doc any, tokens []string, op PatchOp) (any, error) {
+	if len(tokens) == 0 {
+		switch op.Op {
+		case "add", "replace":
+			return op.Value, nil
+		case "remove":
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("unsupported patch operation %q", op.Op)
+		}
+	}
+
+	switch d := doc.(type) {
+	case map[string]any:
+		key := tokens[0]
+		if len(tokens) > 1 {
+			child, ok := d[key]
+			if !ok {
+				return nil, fmt.Errorf("path %s not found", op.Path)
+			}
+			newChild, err := applyOp(child, tokens[1:], op)
+			if err != nil {
+				return nil, err
+			}
+			d[key] = newChild
+			return d, nil
+		}
+
+		switch op.Op {
+		case "add", "replace":
+			d[key] = op.Value
+		case "remove":
+			if _, ok := d[key]; !ok {
+				return nil, fmt.Errorf("path %s not found", op.Path)
+			}
+			delete(d, key)
+		default:
+			return nil, fmt.Errorf("unsupported patch operation %q", op.Op)
+		}
+		return d, nil
+	case []any:
+		var i int
+		if tokens[0] == "-" {
+			i = len(d)
+		} else {
+			var err error
+			i, err = strconv.Atoi(tokens[0])
+			if err != nil || i < 0 || i > len(d) {
+				return nil, fmt.Errorf("invalid array index in path %s", op.Path)
+			}
+		}
+
+		if len(tokens) > 1 {
+			if i == len(d) {
+				return nil, fmt.Errorf("path %s not found", op.Path)
+			}
+			newChild, err := applyOp(d[i], tokens[1:], op)
+			if err != nil {
+				return nil, err
+			}
+			d[i] = newChild
+			return d, nil
+		}
+
+		switch op.Op {
+		case "add":
+			d = append(d, nil)
+			copy(d[i+1:], d[i:])
+			d[i] = op.Value
+		case "replace":
+			if i == len(d) {
+				return nil, fmt.Errorf("path %s not found", op.Path)
+			}
+			d[i] = op.Value
+		case "remove":
+			if i == len(d) {
+				return nil, fmt.Errorf("path %s not found", op.Path)
+			}
+			d = append(d[:i], d[i+1:]...)
+		default:
+			return nil, fmt.Errorf("unsupported patch operation %q", op.Op)
+		}
+		return d, nil
+	default:
+		return nil, fmt.Errorf("path %s not found", op.Path)
+	}
+}
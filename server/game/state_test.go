@@ -0,0 +1,152 @@
+package game
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeState(t *testing.T, s string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestDiffApply(t *testing.T) {
+	cases := []struct {
+		name string
+		from string
+		to   string
+	}{
+		{"equal", `{"a":1}`, `{"a":1}`},
+		{"replace scalar", `{"a":1,"b":"x"}`, `{"a":2,"b":"x"}`},
+		{"add key", `{"a":1}`, `{"a":1,"b":{"c":[1,2]}}`},
+		{"remove key", `{"a":1,"b":2}`, `{"a":1}`},
+		{"grow array", `{"a":[1]}`, `{"a":[1,2,3]}`},
+		{"shrink array", `{"a":[1,2,3,4]}`, `{"a":[1]}`},
+		{"nested", `{"p":[{"id":"a","cards":[]},{"id":"b","cards":[]}]}`, `{"p":[{"id":"a","cards":[{"id":"c1"}]}]}`},
+		{"null to array", `{"a":null}`, `{"a":[1]}`},
+		{"value to null", `{"a":{"b":1},"c":[1]}`, `{"a":null,"c":[null]}`},
+		{"escaped keys", `{"a/b":1,"c~d":2}`, `{"a/b":3,"c~d":4}`},
+		{"root type change", `{"a":1}`, `[1]`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			from := decodeState(t, c.from)
+			to := decodeState(t, c.to)
+
+			patch := diffState("", from, to, nil)
+			if c.from == c.to {
+				assert.Empty(t, patch)
+			}
+
+			// patches are applied by clients after a round trip through JSON
+			data, err := json.Marshal(patch)
+			assert.NoError(t, err)
+			var ops []map[string]any
+			assert.NoError(t, json.Unmarshal(data, &ops))
+			for _, op := range ops {
+				if op["op"] != "remove" {
+					assert.Contains(t, op, "value", "%s %s", op["op"], op["path"])
+				}
+			}
+			var decoded Patch
+			assert.NoError(t, json.Unmarshal(data, &decoded))
+
+			got, err := ApplyPatch(decodeState(t, c.from), decoded)
+			assert.NoError(t, err)
+			assert.Equal(t, to, got)
+		})
+	}
+}
+
+func TestPatchOpJSON(t *testing.T) {
+	data, err := json.Marshal(Patch{
+		{Op: "replace", Path: "/activeWildCard", Value: nil},
+		{Op: "add", Path: "/a", Value: nil},
+		{Op: "remove", Path: "/b"},
+	})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"op":"replace","path":"/activeWildCard","value":null},
+		{"op":"add","path":"/a","value":null},
+		{"op":"remove","path":"/b"}
+	]`, string(data))
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	doc := decodeState(t, `{"a":[1]}`)
+
+	_, err := ApplyPatch(doc, Patch{{Op: "remove", Path: "/b"}})
+	assert.Error(t, err)
+	_, err = ApplyPatch(doc, Patch{{Op: "replace", Path: "/a/5", Value: 1.0}})
+	assert.Error(t, err)
+	_, err = ApplyPatch(doc, Patch{{Op: "move", Path: "/a"}})
+	assert.Error(t, err)
+	_, err = ApplyPatch(doc, Patch{{Op: "add", Path: "a"}})
+	assert.Error(t, err)
+}
+
+func TestRoomStatePatch(t *testing.T) {
+	r := &Room{Id: "r_test", MaxPlayers: 4}
+	before, err := roomState(r)
+	assert.NoError(t, err)
+
+	r.Players = append(r.Players, &Player{Id: "p_test", Hand: PlayerHand{}})
+	r.GamePhase = GamePhasePlaying
+	after, err := roomState(r)
+	assert.NoError(t, err)
+
+	patch := diffState("", before, after, nil)
+	assert.NotEmpty(t, patch)
+
+	before, _ = roomState(&Room{Id: "r_test", MaxPlayers: 4})
+	got, err := ApplyPatch(before, patch)
+	assert.NoError(t, err)
+	assert.Equal(t, after, got)
+}
+
+func TestAttachState(t *testing.T) {
+	r := &Room{Id: "r_test", MaxPlayers: 4}
+	delta := newTestPlayer("p_delta")
+	delta.capabilities = []string{"delta"}
+	plain := newTestPlayer("p_plain")
+
+	snapshot := r.publishState()
+	for _, p := range []*Player{delta, plain} {
+		m := map[string]any{}
+		p.attachState(m, true, snapshot)
+		assert.Equal(t, snapshot.state, m["room"])
+		assert.Equal(t, snapshot.version, m["version"])
+	}
+
+	r.MaxPlayers = 5
+	update := r.publishState()
+	assert.Equal(t, snapshot.version+1, update.version)
+	assert.Same(t, update, r.publishState(), "unchanged state should keep its version")
+
+	m := map[string]any{}
+	delta.attachState(m, false, update)
+	assert.Equal(t, update.patch, m["patch"])
+	assert.Equal(t, update.version, m["version"])
+
+	m = map[string]any{}
+	plain.attachState(m, false, update)
+	assert.Empty(t, m, "patches are only sent to delta clients")
+
+	// the player misses a version sent only to others
+	r.MaxPlayers = 6
+	r.publishState()
+	r.MaxPlayers = 7
+	update = r.publishState()
+	m = map[string]any{}
+	delta.attachState(m, false, update)
+	assert.Nil(t, m["patch"])
+	assert.Equal(t, update.state, m["room"])
+	assert.Equal(t, update.version, m["version"])
+}
@@ -0,0 +1,196 @@
+package game
+
+import (
+	"cardgame/config"
+	"cardgame/deck"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits applied to client messages. Room limits are configured in
+// config.RoomConfig.
+const (
+	maxPasswordLength = 128
+	maxChatLength     = 1000
+	maxIdLength       = 128
+	maxDeckChanges    = 100
+	maxCapabilities   = 32
+	maxSlowMode       = 60 * 60      // seconds
+	maxMuteDuration   = 24 * 60 * 60 // seconds
+	maxAutoStart      = 60           // seconds
+)
+
+// checkMessage applies the connection's rate limits and validates a client
+// message against the limits in c. It returns an error if the message is
+// rejected.
+func checkMessage(msg ClientMessage, c *config.Config, limiter *rateLimiter, now time.Time) error {
+	if !limiter.allow(msg.ClientType(), now) {
+		return newError(ErrorRateLimited, "too many "+msg.ClientType()+" messages")
+	}
+
+	if v, ok := msg.(validator); ok {
+		return v.validate(c)
+	}
+
+	return nil
+}
+
+// validator is implemented by client messages with constraints beyond what
+// parsing enforces. validate is called before the message is handled.
+type validator interface{ validate(c *config.Config) error }
+
+// invalidField returns a validation error for the given field. The field is
+// included in the error details.
+func invalidField(field string, format string, args ...any) error {
+	return newError(ErrorInvalidMessage, field+" "+fmt.Sprintf(format, args...)).with("field", field)
+}
+
+func checkLength(field string, s string, max int) error {
+	if !utf8.ValidString(s) {
+		return invalidField(field, "must be valid UTF-8")
+	}
+	if n := utf8.RuneCountInString(s); n > max {
+		return invalidField(field, "must be at most %d characters, got %d", max, n)
+	}
+	return nil
+}
+
+func checkDecks(field string, ids []string) error {
+	if len(ids) > maxDeckChanges {
+		return invalidField(field, "must contain at most %d decks", maxDeckChanges)
+	}
+	for _, id := range ids {
+		if _, ok := deck.Decks()[id]; !ok {
+			return invalidField(field, "unknown deck %q", id)
+		}
+	}
+	return nil
+}
+
+func (c ClientChangeDetails) validate(cfg *config.Config) error {
+	if c.Name != nil {
+		if err := checkLength("name", *c.Name, cfg.Rooms.MaxNameLength); err != nil {
+			return err
+		}
+		if *c.Name == "" {
+			return invalidField("name", "must not be empty")
+		}
+	}
+	if c.Description != nil {
+		if err := checkLength("description", *c.Description, cfg.Rooms.MaxDescriptionLength); err != nil {
+			return err
+		}
+	}
+	if c.MaxPlayers != nil && (*c.MaxPlayers < config.MinPlayers || *c.MaxPlayers > cfg.Rooms.MaxPlayersLimit) {
+		return invalidField("maxPlayers", "must be between %d and %d", config.MinPlayers, cfg.Rooms.MaxPlayersLimit)
+	}
+	if c.Password != nil {
+		if err := checkLength("password", *c.Password, maxPasswordLength); err != nil {
+			return err
+		}
+	}
+	if err := checkDecks("addDecks", c.AddDecks); err != nil {
+		return err
+	}
+	if err := checkDecks("removeDecks", c.RemoveDecks); err != nil {
+		return err
+	}
+	if c.PlayMode != nil && !c.PlayMode.Valid() {
+		return invalidField("playMode", "unknown play mode %d", *c.PlayMode)
+	}
+	if c.LeaveCards != nil && !c.LeaveCards.Valid() {
+		return invalidField("leaveCards", "is not a valid option")
+	}
+	if c.AutoStart != nil && (*c.AutoStart < 0 || *c.AutoStart > maxAutoStart) {
+		return invalidField("autoStart", "must be between 0 and %d", maxAutoStart)
+	}
+	if c.SlowMode != nil && (*c.SlowMode < 0 || *c.SlowMode > maxSlowMode) {
+		return invalidField("slowMode", "must be between 0 and %d", maxSlowMode)
+	}
+	if c.HubDeviceId != nil {
+		return checkLength("hubDeviceId", *c.HubDeviceId, maxIdLength)
+	}
+	return nil
+}
+
+func (c ClientJoin) validate(cfg *config.Config) error {
+	if err := checkLength("roomId", c.RoomId, maxIdLength); err != nil {
+		return err
+	}
+	if err := checkLength("name", c.Name, cfg.Rooms.MaxNameLength); err != nil {
+		return err
+	}
+	return checkLength("password", c.Password, maxPasswordLength)
+}
+
+func (c ClientKick) validate(_ *config.Config) error {
+	return checkLength("id", c.Id, maxIdLength)
+}
+
+func (c ClientVoteKick) validate(_ *config.Config) error {
+	return checkLength("id", c.Id, maxIdLength)
+}
+
+func (c ClientTransferOwnership) validate(_ *config.Config) error {
+	return checkLength("id", c.Id, maxIdLength)
+}
+
+func (c ClientSend) validate(_ *config.Config) error {
+	if c.Player != nil && c.RecipientId == c.Player.Id {
+		return invalidField("recipientId", "must not be the sender")
+	}
+	return checkLength("recipientId", c.RecipientId, maxIdLength)
+}
+
+func (c ClientChat) validate(_ *config.Config) error {
+	if c.RecipientId != nil {
+		if err := checkLength("recipient", *c.RecipientId, maxIdLength); err != nil {
+			return err
+		}
+	}
+	return checkLength("message", c.Message, maxChatLength)
+}
+
+func (c ClientDeleteChat) validate(_ *config.Config) error {
+	return checkLength("id", c.Id, maxIdLength)
+}
+
+func (c ClientMute) validate(_ *config.Config) error {
+	if c.Duration < 0 || c.Duration > maxMuteDuration {
+		return invalidField("duration", "must be between 0 and %d", maxMuteDuration)
+	}
+	return checkLength("id", c.Id, maxIdLength)
+}
+
+func (c ClientHello) validate(_ *config.Config) error {
+	if len(c.Capabilities) > maxCapabilities {
+		return invalidField("capabilities", "must contain at most %d entries", maxCapabilities)
+	}
+	for _, capability := range c.Capabilities {
+		if err := checkLength("capabilities", capability, maxIdLength); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c ClientAck) validate(_ *config.Config) error {
+	if c.Seq < 0 {
+		return invalidField("seq", "must not be negative")
+	}
+	return nil
+}
+
+func (c ClientReconnect) validate(_ *config.Config) error {
+	if c.LastSeq < 0 {
+		return invalidField("lastSeq", "must not be negative")
+	}
+	if err := checkLength("roomId", c.RoomId, maxIdLength); err != nil {
+		return err
+	}
+	if err := checkLength("playerId", c.PlayerId, maxIdLength); err != nil {
+		return err
+	}
+	return checkLength("token", c.Token, maxIdLength)
+}
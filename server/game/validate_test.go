@@ -0,0 +1,112 @@
+package game
+
+import (
+	"cardgame/config"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	cfg := config.Default()
+	cfg.Rooms.MaxNameLength = 20
+	cfg.Rooms.MaxPlayersLimit = 6
+	str := func(s string) *string { return &s }
+	num := func(n int) *int { return &n }
+	mode := func(m PlayMode) *PlayMode { return &m }
+	leave := func(l LeaveCards) *LeaveCards { return &l }
+
+	cases := []struct {
+		message validator
+		field   string // empty if valid
+	}{
+		{ClientChangeDetails{Name: str("Friday games")}, ""},
+		{ClientChangeDetails{Name: str("")}, "name"},
+		{ClientChangeDetails{Name: str(strings.Repeat("x", cfg.Rooms.MaxNameLength+1))}, "name"},
+		{ClientChangeDetails{Name: str("\xff")}, "name"},
+		{ClientChangeDetails{Description: str(strings.Repeat("x", 100_000))}, "description"},
+		{ClientChangeDetails{Name: str(strings.Repeat("x", cfg.Rooms.MaxNameLength))}, ""},
+		{ClientChangeDetails{MaxPlayers: num(6)}, ""},
+		{ClientChangeDetails{MaxPlayers: num(-5)}, "maxPlayers"},
+		{ClientChangeDetails{MaxPlayers: num(cfg.Rooms.MaxPlayersLimit + 1)}, "maxPlayers"},
+		{ClientChangeDetails{AddDecks: []string{"d_missing"}}, "addDecks"},
+		{ClientChangeDetails{RemoveDecks: []string{"d_missing"}}, "removeDecks"},
+		{ClientChangeDetails{PlayMode: mode(PlayModeHubOnly)}, ""},
+		{ClientChangeDetails{PlayMode: mode(7)}, "playMode"},
+		{ClientChangeDetails{SlowMode: num(30)}, ""},
+		{ClientChangeDetails{SlowMode: num(maxSlowMode + 1)}, "slowMode"},
+		{ClientChangeDetails{LeaveCards: leave(LeaveCardsDiscard)}, ""},
+		{ClientChangeDetails{LeaveCards: leave(3)}, "leaveCards"},
+		{ClientChangeDetails{AutoStart: num(10)}, ""},
+		{ClientChangeDetails{AutoStart: num(-1)}, "autoStart"},
+		{ClientChat{Message: "hi"}, ""},
+		{ClientChat{Message: strings.Repeat("x", maxChatLength+1)}, "message"},
+		{ClientJoin{RoomId: "r_1234", Password: strings.Repeat("x", maxPasswordLength+1)}, "password"},
+		{ClientJoin{RoomId: "r_1234", Name: strings.Repeat("x", cfg.Rooms.MaxNameLength+1)}, "name"},
+		{ClientSend{Player: &Player{Id: "p_1234"}, RecipientId: "p_5678"}, ""},
+		{ClientSend{Player: &Player{Id: "p_1234"}, RecipientId: "p_1234"}, "recipientId"},
+		{ClientDeleteChat{Id: strings.Repeat("x", maxIdLength+1)}, "id"},
+		{ClientMute{Id: "p_1234", Duration: 60}, ""},
+		{ClientMute{Id: "p_1234", Duration: -1}, "duration"},
+		{ClientKick{Id: strings.Repeat("x", maxIdLength+1)}, "id"},
+		{ClientHello{ProtocolVersion: 1, Capabilities: make([]string, maxCapabilities+1)}, "capabilities"},
+		{ClientAck{Seq: -1}, "seq"},
+		{ClientReconnect{LastSeq: -1}, "lastSeq"},
+	}
+
+	for _, c := range cases {
+		err := c.message.validate(cfg)
+		if c.field == "" {
+			assert.NoError(t, err, "%#v", c.message)
+			continue
+		}
+		if assert.Error(t, err, "%#v", c.message) {
+			assert.Equal(t, c.field, err.(*Error).Details["field"])
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	limits := config.Default().RateLimits
+	l := newRateLimiter(limits, now)
+
+	limit := limits.PerType["chat"]
+	for i := 0; i < int(limit.Burst); i++ {
+		assert.True(t, l.allow("chat", now), "burst message %d", i)
+	}
+	assert.False(t, l.allow("chat", now), "burst exhausted")
+	assert.True(t, l.allow("draw", now), "other types are limited separately")
+
+	now = now.Add(time.Duration(float64(time.Second) / limit.Rate))
+	assert.True(t, l.allow("chat", now), "tokens refill over time")
+	assert.False(t, l.allow("chat", now))
+}
+
+func TestCheckMessage(t *testing.T) {
+	now := time.Now()
+	cfg := config.Default()
+	l := newRateLimiter(cfg.RateLimits, now)
+
+	message := ClientChangeDetails{MaxPlayers: new(int)}
+	e := serverError(message, checkMessage(message, cfg, l, now))
+	assert.Equal(t, ErrorInvalidMessage, e.Code)
+	assert.Equal(t, "maxPlayers", e.Details["field"])
+	assert.Equal(t, "change_details", e.Request)
+
+	var rejected error
+	for i := 0; i < 100 && rejected == nil; i++ {
+		rejected = checkMessage(ClientDraw{}, cfg, l, now)
+	}
+	if assert.Error(t, rejected) {
+		assert.Equal(t, ErrorRateLimited, rejected.(*Error).Code)
+	}
+
+	violations := 0
+	for l.violation(now) {
+		violations++
+	}
+	assert.Equal(t, int(cfg.RateLimits.Violations.Burst), violations)
+}
@@ -1,45 +1,102 @@
 package main
 
 import (
-	"github.com/gin-contrib/cors"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 
 	"cardgame/build"
+	"cardgame/config"
 	"cardgame/deck"
 	"cardgame/game"
+	"cardgame/util/logging"
 	"cardgame/web"
 )
 
 func main() {
-	if build.Mode() == "release" {
-		gin.SetMode(gin.ReleaseMode)
-	} else {
-		godotenv.Load()
+	// .env is optional; variables already set take precedence
+	godotenv.Load()
+
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if err != nil {
+		logging.Default.Error("failed to load config", "error", err)
+		os.Exit(1)
+	}
+	logger := cfg.Logger()
+	logging.Default = logger
+
+	game.HubMain = game.NewHub(cfg, logger)
+	if len(cfg.ChatFilter) > 0 {
+		game.ChatFilter = game.NewWordFilter(cfg.ChatFilter)
+	}
 
-		room := game.HubMain.NewRoom("")
-		delete(game.HubMain.Rooms, room.Id)
-		room.Id = "r_debug"
-		game.HubMain.Rooms[room.Id] = room
+	if build.Mode() != "release" {
+		game.HubMain.NewRoomWithId("r_debug", "")
 	}
 
-	deck.InitDecks("./data/decks")
+	for _, dir := range cfg.DeckDirs {
+		deck.InitDecks(dir)
+	}
 
 	gin.SetMode(gin.ReleaseMode)
+	r := web.NewRouter(cfg)
+
+	srv := &http.Server{Addr: cfg.Addr, Handler: r}
+
+	go func() {
+		logging.Default.Info("listening", "addr", cfg.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Default.Error("server failed", "error", err)
+			os.Exit(1)
+		}
+	}()
 
-	r := gin.Default()
-	r.SetTrustedProxies([]string{})
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
 
-	c := cors.DefaultConfig()
-	c.AllowAllOrigins = true
-	c.AllowHeaders = []string{"Origin", "Content-Type", "X-Password"}
-	r.Use(cors.New(c))
+	shutdown(cfg, srv)
+}
+
+// shutdown drains the hub and stops the HTTP server within the configured
+// timeout. If a snapshot file is configured, the state of every room is
+// written to it first.
+func shutdown(cfg *config.Config, srv *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeouts.Shutdown)
+	defer cancel()
 
-	web.InitApi(r.Group("/api"))
+	if path := cfg.ShutdownSnapshot; path != "" {
+		if err := writeSnapshot(path); err != nil {
+			logging.Default.Error("failed to write snapshot", "path", path, "error", err)
+		} else {
+			logging.Default.Info("wrote snapshot", "path", path)
+		}
+	}
 
-	r.Use(func(c *gin.Context) {
-		c.AbortWithStatusJSON(404, gin.H{"error": "not found"})
-	})
+	if err := game.HubMain.Shutdown(ctx, "server shutting down"); err != nil {
+		logging.Default.Warn("hub shutdown incomplete", "error", err)
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		logging.Default.Warn("http shutdown incomplete", "error", err)
+	}
+	logging.Default.Info("stopped")
+}
 
-	r.Run()
+func writeSnapshot(path string) error {
+	snapshot, err := game.HubMain.Snapshot()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
 }
@@ -4,6 +4,7 @@ import (
 	"cardgame/card"
 	"cardgame/deck"
 	"cardgame/game"
+	"fmt"
 	"reflect"
 	"strings"
 
@@ -17,9 +18,12 @@ func main() {
 		Add(deck.Deck{}).
 		Add(card.Card{}).
 		Add(card.WildCard{}).
+		Add(game.PatchOp{}).
 		AddEnum(game.TSAllGamePhases).
 		AddEnum(game.TSAllPlayModes).
-		AddEnum(card.TSAllCardTypes)
+		AddEnum(game.TSAllLeaveCards).
+		AddEnum(card.TSAllCardTypes).
+		AddEnum(game.TSAllErrorCodes)
 
 	for _, typ := range game.ClientMessageTypes {
 		converter = converter.Add(typ)
@@ -30,6 +34,7 @@ func main() {
 	}
 
 	var extras strings.Builder
+	extras.WriteString(fmt.Sprintf("export const PROTOCOL_VERSION = %d;\n\n", game.ProtocolVersion))
 	// export type ClientMessage = { type: "join" } & ClientJoin | { type: "leave" } & ClientLeave;
 	extras.WriteString("export type ClientMessage =\n")
 	for t, typ := range game.ClientMessageTypes {
@@ -37,7 +42,7 @@ func main() {
 		extras.WriteString("    | ")
 		extras.WriteString("({ type: \"")
 		extras.WriteString(t)
-		extras.WriteString("\" } & ")
+		extras.WriteString("\"; requestId?: string } & ")
 		extras.WriteString(typeName)
 		extras.WriteString(")\n")
 	}
@@ -46,9 +51,9 @@ func main() {
 	for t, typ := range game.ServerMessageTypes {
 		typeName := reflect.TypeOf(typ).Name()
 		extras.WriteString("    | ")
-		extras.WriteString("({ room: Room; type: \"")
+		extras.WriteString("({ type: \"")
 		extras.WriteString(t)
-		extras.WriteString("\" } & ")
+		extras.WriteString("\"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ")
 		extras.WriteString(typeName)
 		extras.WriteString(")\n")
 	}
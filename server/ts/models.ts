@@ -1,29 +1,61 @@
 /* Do not change, this code is generated from Golang structs */
 
+export const PROTOCOL_VERSION = 1;
+
 export type ClientMessage =
-    | ({ type: "change_details" } & ClientChangeDetails)
-    | ({ type: "join" } & ClientJoin)
-    | ({ type: "leave" } & ClientLeave)
-    | ({ type: "kick" } & ClientKick)
-    | ({ type: "start" } & ClientStart)
-    | ({ type: "draw" } & ClientDraw)
-    | ({ type: "send" } & ClientSend)
-    | ({ type: "chat" } & ClientChat)
+    | ({ type: "delete_chat"; requestId?: string } & ClientDeleteChat)
+    | ({ type: "vote_kick"; requestId?: string } & ClientVoteKick)
+    | ({ type: "transfer_ownership"; requestId?: string } & ClientTransferOwnership)
+    | ({ type: "ready"; requestId?: string } & ClientReady)
+    | ({ type: "pause"; requestId?: string } & ClientPause)
+    | ({ type: "send"; requestId?: string } & ClientSend)
+    | ({ type: "mute"; requestId?: string } & ClientMute)
+    | ({ type: "ack"; requestId?: string } & ClientAck)
+    | ({ type: "reconnect"; requestId?: string } & ClientReconnect)
+    | ({ type: "join"; requestId?: string } & ClientJoin)
+    | ({ type: "kick"; requestId?: string } & ClientKick)
+    | ({ type: "draw"; requestId?: string } & ClientDraw)
+    | ({ type: "request_snapshot"; requestId?: string } & ClientRequestSnapshot)
+    | ({ type: "hello"; requestId?: string } & ClientHello)
+    | ({ type: "change_details"; requestId?: string } & ClientChangeDetails)
+    | ({ type: "leave"; requestId?: string } & ClientLeave)
+    | ({ type: "start"; requestId?: string } & ClientStart)
+    | ({ type: "resume"; requestId?: string } & ClientResume)
+    | ({ type: "chat"; requestId?: string } & ClientChat)
 
 export type ServerMessage =
-    | ({ room: Room; type: "join" } & ServerJoin)
-    | ({ room: Room; type: "leave" } & ServerLeave)
-    | ({ room: Room; type: "draw" } & ServerDraw)
-    | ({ room: Room; type: "wild_card" } & ServerWildCard)
-    | ({ room: Room; type: "chat" } & ServerChat)
-    | ({ room: Room; type: "change_details" } & ServerChangeDetails)
-    | ({ room: Room; type: "kick" } & ServerKick)
-    | ({ room: Room; type: "start" } & ServerStart)
-    | ({ room: Room; type: "reshuffle" } & ServerReshuffle)
-    | ({ room: Room; type: "resync" } & ServerResync)
-    | ({ room: Room; type: "turn" } & ServerTurn)
-    | ({ room: Room; type: "error" } & ServerError)
-    | ({ room: Room; type: "ack" } & ServerAck)
+    | ({ type: "draw"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerDraw)
+    | ({ type: "change_details"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerChangeDetails)
+    | ({ type: "kick"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerKick)
+    | ({ type: "owner_changed"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerOwnerChanged)
+    | ({ type: "announcement"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerAnnouncement)
+    | ({ type: "pause"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerPause)
+    | ({ type: "join"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerJoin)
+    | ({ type: "room_closed"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerRoomClosed)
+    | ({ type: "ready"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerReady)
+    | ({ type: "start"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerStart)
+    | ({ type: "resume"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerResume)
+    | ({ type: "wild_card"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerWildCard)
+    | ({ type: "resync"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerResync)
+    | ({ type: "turn"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerTurn)
+    | ({ type: "chat"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerChat)
+    | ({ type: "chat_history"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerChatHistory)
+    | ({ type: "session"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerSession)
+    | ({ type: "vote_kick"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerVoteKick)
+    | ({ type: "shutdown"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerShutdown)
+    | ({ type: "ack"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerAck)
+    | ({ type: "countdown"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerCountdown)
+    | ({ type: "hello"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerHello)
+    | ({ type: "reconnect"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerReconnect)
+    | ({ type: "presence"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerPresence)
+    | ({ type: "error"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerError)
+    | ({ type: "reshuffle"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerReshuffle)
+    | ({ type: "send"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerSend)
+    | ({ type: "chat_deleted"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerChatDeleted)
+    | ({ type: "snapshot"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerSnapshot)
+    | ({ type: "leave"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerLeave)
+    | ({ type: "mute"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerMute)
+    | ({ type: "end"; seq: number; version?: number; patch?: PatchOp[]; room?: Room } & ServerEnd)
 
 
 export enum GamePhase {
@@ -36,6 +68,10 @@ export enum PlayMode {
     PlayersAndHub = 1,
     HubOnly = 2,
 }
+export enum LeaveCards {
+    DrawPile = 0,
+    Discard = 1,
+}
 export enum CardType {
     Lines = 0,
     Waves = 1,
@@ -47,6 +83,43 @@ export enum CardType {
     Star = 7,
     Invalid = -1,
 }
+export enum ErrorCode {
+    Internal = "internal",
+    InvalidMessage = "invalid_message",
+    RateLimited = "rate_limited",
+    AlreadyGreeted = "already_greeted",
+    AlreadyInRoom = "already_in_room",
+    NotInRoom = "not_in_room",
+    RoomNotFound = "room_not_found",
+    IncorrectPassword = "incorrect_password",
+    RoomFull = "room_full",
+    InvalidSession = "invalid_session",
+    NotOwner = "not_owner",
+    KickSelf = "kick_self",
+    PlayerNotFound = "player_not_found",
+    GameStarted = "game_started",
+    GameNotPlaying = "game_not_playing",
+    NotYourTurn = "not_your_turn",
+    DrawPileEmpty = "draw_pile_empty",
+    IncompatibleCards = "incompatible_cards",
+    Muted = "muted",
+    SlowMode = "slow_mode",
+    MessageNotFound = "message_not_found",
+    UnknownCommand = "unknown_command",
+    CommandUsage = "command_usage",
+    AmbiguousPlayer = "ambiguous_player",
+    DeckNotFound = "deck_not_found",
+    Banned = "banned",
+    OwnerPresent = "owner_present",
+    Spectating = "spectating",
+    NotEnoughPlayers = "not_enough_players",
+    NoDecks = "no_decks",
+    NotEnoughCards = "not_enough_cards",
+    PlayersNotReady = "players_not_ready",
+    GamePaused = "game_paused",
+    GameNotPaused = "game_not_paused",
+    ShuttingDown = "shutting_down",
+}
 export interface WildCard {
     id: string;
     types: number[];
@@ -75,6 +148,9 @@ export interface Player {
     name: string;
     score: number;
     cards: Card[];
+    connected: boolean;
+    mutedUntil: number;
+    ready: boolean;
 }
 export interface Room {
     id: string;
@@ -84,6 +160,7 @@ export interface Room {
     maxPlayers: number;
     ownerId: string;
     players: Player[];
+    spectators: Player[];
     decks: Deck[];
     playMode: PlayMode;
     hubDeviceId: string;
@@ -91,70 +168,154 @@ export interface Room {
     gamePhase: GamePhase;
     activeWildCard?: WildCard;
     drawPileSize: number;
+    gameStartedAt: number;
+    turnStartedAt: number;
+    paused: boolean;
+    pausedAt: number;
+    slowMode: number;
+    leaveCards: LeaveCards;
+    lateJoin: boolean;
+    autoStart: number;
 }
 
 
 
 
-export interface ClientJoin {
-    name: string;
-    avatar: AvatarConfig;
+export interface PatchOp {
+    op: string;
+    path: string;
+    value?: any;
+}
+export interface ClientChangeDetails {
+    name?: string;
+    description?: string;
+    maxPlayers?: number;
+    password?: string;
+    addDecks: string[];
+    removeDecks: string[];
+    playMode?: PlayMode;
+    hubDeviceId?: string;
+    slowMode?: number;
+    leaveCards?: LeaveCards;
+    lateJoin?: boolean;
+    autoStart?: number;
 }
 export interface ClientLeave {
 
-}
-export interface ClientKick {
-    id: string;
 }
 export interface ClientStart {
 
 }
-export interface ClientDraw {
+export interface ClientResume {
 
-}
-export interface ClientSend {
-    recipientId: string;
 }
 export interface ClientChat {
     message: string;
     recipient?: string;
 }
-export interface ClientChangeDetails {
-    name?: string;
-    description?: string;
-    maxPlayers?: number;
-    password?: string;
-    addDecks: string[];
-    removeDecks: string[];
-    playMode?: PlayMode;
-    hubDeviceId?: string;
+export interface ClientDeleteChat {
+    id: string;
 }
-export interface ServerTurn {
+export interface ClientVoteKick {
+    id: string;
+}
+export interface ClientTransferOwnership {
+    id: string;
+}
+export interface ClientReady {
+    ready: boolean;
+}
+export interface ClientPause {
+
+}
+export interface ClientSend {
+    recipientId: string;
+}
+export interface ClientMute {
+    id: string;
+    duration: number;
+}
+export interface ClientAck {
+    seq: number;
+}
+export interface ClientReconnect {
+    roomId: string;
     playerId: string;
+    token: string;
+    lastSeq: number;
 }
-export interface ServerError {
-    message: string;
+export interface ClientJoin {
+    roomId: string;
+    password: string;
+    name: string;
 }
-export interface ServerAck {
+export interface ClientKick {
+    id: string;
+    ban: boolean;
+}
+export interface ClientDraw {
 
 }
-export interface ServerKick {
+export interface ClientRequestSnapshot {
 
 }
-export interface ServerStart {
-    currentTurn: number;
+export interface ClientHello {
+    protocolVersion: number;
+    capabilities: string[];
+}
+export interface ServerCountdown {
+    seconds: number;
+    startsAt: number;
+}
+export interface ServerHello {
+    protocolVersion: number;
+    version: string;
+    commit: string;
+    features: string[];
+}
+export interface ServerReconnect {
+    replayed: number;
+    snapshot: boolean;
+}
+export interface ServerPresence {
+    id: string;
+    connected: boolean;
+}
+export interface ServerError {
+    code: ErrorCode;
+    message: string;
+    details?: {[key: string]: any};
+    request?: string;
+    requestId?: string;
 }
 export interface ServerReshuffle {
 
 }
-export interface ServerResync {
-    topCards: {[key: string]: Card};
+export interface ServerSend {
+    senderId: string;
+    recipientId: string;
+    card?: Card;
 }
-export interface ServerChat {
-    timestamp: string;
-    player: string;
-    private: boolean;
-    message: string;
+export interface ServerChatDeleted {
+    id: string;
+}
+export interface ServerSnapshot {
+
+}
+export interface ServerLeave {
+    id: string;
+    kicked: boolean;
+}
+export interface ServerMute {
+    playerId: string;
+    until: number;
+}
+export interface ServerEnd {
+    reason: string;
+}
+export interface ServerDraw {
+    playerId: string;
+    card?: Card;
 }
 export interface ServerChangeDetails {
     name?: string;
@@ -164,18 +325,77 @@ export interface ServerChangeDetails {
     playMode?: PlayMode;
     hubDeviceId?: string;
 }
+export interface ServerKick {
+    banned: boolean;
+}
+export interface ServerOwnerChanged {
+    ownerId: string;
+    previousId: string;
+}
+export interface ServerAnnouncement {
+    timestamp: number;
+    message: string;
+}
+export interface ServerPause {
+    playerId: string;
+    automatic: boolean;
+}
 export interface ServerJoin {
     id: string;
-    player: Player;
+    player?: Player;
+    spectator: boolean;
 }
-export interface ServerLeave {
-    id: string;
+export interface ServerRoomClosed {
+    reason: string;
 }
-export interface ServerDraw {
+export interface ServerReady {
     playerId: string;
-    card?: Card;
+    ready: boolean;
+}
+export interface ServerStart {
+    currentTurn: number;
+}
+export interface ServerResume {
+    playerId: string;
+    duration: number;
 }
 export interface ServerWildCard {
     playerId: string;
     card?: WildCard;
+}
+export interface ServerResync {
+    topCards: {[key: string]: Card};
+}
+export interface ServerTurn {
+    playerId: string;
+}
+export interface ServerChat {
+    id: string;
+    timestamp: string;
+    player: string;
+    private: boolean;
+    system: boolean;
+    message: string;
+}
+export interface ServerChatHistory {
+    messages: ServerChat[];
+}
+export interface ServerSession {
+    playerId: string;
+    token: string;
+}
+export interface ServerVoteKick {
+    playerId: string;
+    voterId: string;
+    votes: number;
+    required: number;
+}
+export interface ServerShutdown {
+    reason: string;
+    deadline: number;
+    reconnectAfter: number;
+}
+export interface ServerAck {
+    action: string;
+    requestId?: string;
 }
\ No newline at end of file
@@ -21,3 +21,8 @@ func LongIdFrom(prefix string, text string) string {
 	h := fmt.Sprintf("%x", sha3.Sum256([]byte(text)))
 	return fmt.Sprintf("%s_%s", prefix, h)
 }
+
+// SessionToken returns a random token used to authenticate reconnects.
+func SessionToken() string {
+	return gonanoid.MustID(32)
+}
@@ -0,0 +1,294 @@
+// Package logging implements a small structured, leveled logger that writes
+// logfmt or JSON lines.
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Level is the severity of a log entry.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return "level(" + strconv.Itoa(int(l)) + ")"
+}
+
+// ParseLevel parses a level name such as "info".
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// Format is the encoding of log entries.
+type Format string
+
+const (
+	FormatLogfmt Format = "logfmt"
+	FormatJSON   Format = "json"
+)
+
+// ParseFormat parses a format name such as "json".
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case FormatLogfmt, FormatJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown log format %q", s)
+}
+
+// Redacted replaces the values of redacted keys.
+const Redacted = "[redacted]"
+
+// DefaultRedact are the keys whose values are never written. "message" holds
+// the text of chat messages.
+var DefaultRedact = []string{"password", "token", "session", "message"}
+
+// output is shared by a logger and all loggers derived from it with With.
+type output struct {
+	mu     sync.Mutex
+	w      io.Writer
+	level  Level
+	format Format
+	redact map[string]bool
+	now    func() time.Time
+}
+
+// Logger writes structured log entries. Loggers derived with With share the
+// writer and settings of their parent. A nil *Logger discards everything.
+type Logger struct {
+	out    *output
+	fields []any // alternating keys and values
+}
+
+// New returns a logger writing entries of at least the given level to w.
+func New(w io.Writer, level Level, format Format) *Logger {
+	l := &Logger{out: &output{
+		w:      w,
+		level:  level,
+		format: format,
+		redact: map[string]bool{},
+		now:    time.Now,
+	}}
+	l.Redact(DefaultRedact...)
+	return l
+}
+
+// Default writes info and above to stderr in logfmt.
+var Default = New(os.Stderr, LevelInfo, FormatLogfmt)
+
+// SetLevel changes the minimum level of the logger and all loggers sharing
+// its output.
+func (l *Logger) SetLevel(level Level) {
+	if l == nil {
+		return
+	}
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.level = level
+}
+
+// SetFormat changes the format of the logger and all loggers sharing its
+// output.
+func (l *Logger) SetFormat(format Format) {
+	if l == nil {
+		return
+	}
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.format = format
+}
+
+// Redact adds keys whose values are replaced with Redacted.
+func (l *Logger) Redact(keys ...string) {
+	if l == nil {
+		return
+	}
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	for _, k := range keys {
+		l.out.redact[k] = true
+	}
+}
+
+// Enabled returns true if entries of the given level are written.
+func (l *Logger) Enabled(level Level) bool {
+	if l == nil {
+		return false
+	}
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	return level >= l.out.level
+}
+
+// With returns a logger that adds the given key-value pairs to every entry.
+func (l *Logger) With(keyvals ...any) *Logger {
+	if l == nil {
+		return nil
+	}
+	fields := make([]any, 0, len(l.fields)+len(keyvals))
+	fields = append(append(fields, l.fields...), keyvals...)
+	return &Logger{out: l.out, fields: fields}
+}
+
+// Debug logs a message at LevelDebug.
+func (l *Logger) Debug(msg string, keyvals ...any) { l.Log(LevelDebug, msg, keyvals...) }
+
+// Info logs a message at LevelInfo.
+func (l *Logger) Info(msg string, keyvals ...any) { l.Log(LevelInfo, msg, keyvals...) }
+
+// Warn logs a message at LevelWarn.
+func (l *Logger) Warn(msg string, keyvals ...any) { l.Log(LevelWarn, msg, keyvals...) }
+
+// Error logs a message at LevelError.
+func (l *Logger) Error(msg string, keyvals ...any) { l.Log(LevelError, msg, keyvals...) }
+
+// Log writes an entry with the logger's fields followed by keyvals, which
+// alternate between string keys and values.
+func (l *Logger) Log(level Level, msg string, keyvals ...any) {
+	if l == nil {
+		return
+	}
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	if level < l.out.level {
+		return
+	}
+
+	e := entry{format: l.out.format}
+	e.add("time", l.out.now().UTC().Format(time.RFC3339Nano))
+	e.add("level", level.String())
+	e.add("msg", msg)
+	for _, kv := range [][]any{l.fields, keyvals} {
+		for i := 0; i < len(kv); i += 2 {
+			key := fmt.Sprint(kv[i])
+			var value any = "(missing)"
+			if i+1 < len(kv) {
+				value = kv[i+1]
+			}
+			if l.out.redact[key] {
+				value = Redacted
+			}
+			e.add(key, value)
+		}
+	}
+	e.end()
+	io.WriteString(l.out.w, e.buf.String())
+}
+
+// entry builds a single log line.
+type entry struct {
+	format Format
+	buf    strings.Builder
+	n      int
+}
+
+// add appends a field to the entry. Errors and Stringers are written as
+// strings.
+func (b *entry) add(key string, value any) {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	} else if s, ok := value.(fmt.Stringer); ok {
+		value = s.String()
+	}
+
+	if b.format == FormatJSON {
+		if b.n == 0 {
+			b.buf.WriteByte('{')
+		} else {
+			b.buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		b.buf.Write(k)
+		b.buf.WriteByte(':')
+		v, err := json.Marshal(value)
+		if err != nil {
+			v, _ = json.Marshal(fmt.Sprint(value))
+		}
+		b.buf.Write(v)
+	} else {
+		if b.n > 0 {
+			b.buf.WriteByte(' ')
+		}
+		b.buf.WriteString(logfmtKey(key))
+		b.buf.WriteByte('=')
+		b.buf.WriteString(logfmtValue(value))
+	}
+	b.n++
+}
+
+func (b *entry) end() {
+	if b.format == FormatJSON {
+		b.buf.WriteByte('}')
+	}
+	b.buf.WriteByte('\n')
+}
+
+func logfmtKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == unicode.ReplacementChar {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+func logfmtValue(value any) string {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case nil:
+		return "null"
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			s = fmt.Sprint(v)
+		} else {
+			s = string(data)
+		}
+	}
+
+	if s == "" {
+		return `""`
+	}
+	if strings.ContainsAny(s, " =\"\\") || strings.IndexFunc(s, func(r rune) bool { return r < ' ' || r == unicode.ReplacementChar }) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLogger(level Level, format Format) (*Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	l := New(&buf, level, format)
+	l.out.now = func() time.Time { return time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC) }
+	return l, &buf
+}
+
+func TestLogfmt(t *testing.T) {
+	l, buf := newTestLogger(LevelInfo, FormatLogfmt)
+	l.With("room", "r_1").Info("player joined", "player", "p_1", "spectator", false, "note", "two words")
+
+	assert.Equal(t, `time=2022-06-01T12:00:00Z level=info msg="player joined" room=r_1 player=p_1 spectator=false note="two words"`+"\n", buf.String())
+}
+
+func TestJSON(t *testing.T) {
+	l, buf := newTestLogger(LevelInfo, FormatJSON)
+	l.With("room", "r_1").Warn("oops", "count", 3)
+
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, map[string]any{
+		"time":  "2022-06-01T12:00:00Z",
+		"level": "warn",
+		"msg":   "oops",
+		"room":  "r_1",
+		"count": float64(3),
+	}, entry)
+}
+
+func TestLevel(t *testing.T) {
+	l, buf := newTestLogger(LevelWarn, FormatLogfmt)
+	l.Info("hidden")
+	l.Debug("hidden")
+	assert.Empty(t, buf.String())
+	assert.False(t, l.Enabled(LevelInfo))
+
+	l.SetLevel(LevelDebug)
+	l.With("a", 1).Debug("shown")
+	assert.Contains(t, buf.String(), "msg=shown")
+
+	level, err := ParseLevel("WARNING")
+	assert.NoError(t, err)
+	assert.Equal(t, LevelWarn, level)
+	_, err = ParseLevel("loud")
+	assert.Error(t, err)
+}
+
+func TestRedact(t *testing.T) {
+	l, buf := newTestLogger(LevelInfo, FormatLogfmt)
+	l.With("password", "hunter2").Info("chat", "message", "secret text", "token", "abc")
+	assert.NotContains(t, buf.String(), "hunter2")
+	assert.NotContains(t, buf.String(), "secret")
+	assert.NotContains(t, buf.String(), "abc")
+	assert.Contains(t, buf.String(), `message=[redacted]`)
+
+	buf.Reset()
+	l.Redact("email")
+	l.Info("signup", "email", "a@example.com")
+	assert.NotContains(t, buf.String(), "a@example.com")
+}
+
+func TestNilLogger(t *testing.T) {
+	var l *Logger
+	assert.NotPanics(t, func() {
+		l.With("a", 1).Info("nothing")
+		l.SetLevel(LevelDebug)
+	})
+}
@@ -0,0 +1,369 @@
+// Package metrics implements counters, gauges and histograms that can be
+// exported in the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Registry holds metrics and writes them in the Prometheus text format.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(w io.Writer) error
+}
+
+// Default is the registry served on /metrics.
+var Default = &Registry{}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes all metrics in the order they were registered.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	metrics := append([]metric{}, r.metrics...)
+	r.mu.Unlock()
+
+	for _, m := range metrics {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DefaultBuckets are histogram buckets in seconds suitable for request
+// latencies.
+var DefaultBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}
+
+// Counter is a value that only goes up.
+type Counter struct {
+	bits uint64 // float64 bits
+}
+
+// Add adds v to the counter. v must not be negative.
+func (c *Counter) Add(v float64) {
+	for {
+		old := atomic.LoadUint64(&c.bits)
+		next := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(&c.bits, old, next) {
+			return
+		}
+	}
+}
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Value returns the current value of the counter.
+func (c *Counter) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&c.bits))
+}
+
+// Histogram counts observations in buckets.
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64 // upper bounds, sorted
+	counts  []uint64  // per bucket, not cumulative; the last one is +Inf
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)+1),
+	}
+}
+
+// Observe adds a value to the histogram.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// vec holds one child metric per combination of label values.
+type vec[T any] struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu       sync.Mutex
+	children map[string]T
+	values   map[string][]string // key -> label values
+	create   func() T
+}
+
+func newVec[T any](name, help, kind string, labels []string, create func() T) *vec[T] {
+	return &vec[T]{
+		name:     name,
+		help:     help,
+		kind:     kind,
+		labels:   labels,
+		children: map[string]T{},
+		values:   map[string][]string{},
+		create:   create,
+	}
+}
+
+// with returns the child for the given label values, creating it if needed.
+func (v *vec[T]) with(values []string) T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", v.name, len(v.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	child, ok := v.children[key]
+	if !ok {
+		child = v.create()
+		v.children[key] = child
+		v.values[key] = append([]string{}, values...)
+	}
+	return child
+}
+
+// each calls f for every child, sorted by label values.
+func (v *vec[T]) each(f func(values []string, child T) error) error {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.children))
+	for k := range v.children {
+		keys = append(keys, k)
+	}
+	children := make(map[string]T, len(v.children))
+	for k, c := range v.children {
+		children[k] = c
+	}
+	v.mu.Unlock()
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := f(v.values[k], children[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *vec[T]) header(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, escapeHelp(v.help), v.name, v.kind)
+	return err
+}
+
+// CounterVec is a set of counters partitioned by labels.
+type CounterVec struct {
+	*vec[*Counter]
+}
+
+// CounterVec creates and registers a counter with the given label names.
+func (r *Registry) CounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec(name, help, "counter", labels, func() *Counter { return &Counter{} })}
+	r.register(c)
+	return c
+}
+
+// With returns the counter for the given label values.
+func (c *CounterVec) With(values ...string) *Counter {
+	return c.with(values)
+}
+
+func (c *CounterVec) write(w io.Writer) error {
+	if err := c.header(w); err != nil {
+		return err
+	}
+	return c.each(func(values []string, child *Counter) error {
+		return writeSample(w, c.name, c.labels, values, child.Value())
+	})
+}
+
+// HistogramVec is a set of histograms partitioned by labels.
+type HistogramVec struct {
+	*vec[*Histogram]
+	buckets []float64
+}
+
+// HistogramVec creates and registers a histogram with the given buckets and
+// label names.
+func (r *Registry) HistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	h := &HistogramVec{
+		vec:     newVec(name, help, "histogram", labels, func() *Histogram { return newHistogram(buckets) }),
+		buckets: buckets,
+	}
+	r.register(h)
+	return h
+}
+
+// With returns the histogram for the given label values.
+func (h *HistogramVec) With(values ...string) *Histogram {
+	return h.with(values)
+}
+
+func (h *HistogramVec) write(w io.Writer) error {
+	if err := h.header(w); err != nil {
+		return err
+	}
+	return h.each(func(values []string, child *Histogram) error {
+		child.mu.Lock()
+		counts := append([]uint64{}, child.counts...)
+		sum, count := child.sum, child.count
+		child.mu.Unlock()
+
+		labels := append(append([]string{}, h.labels...), "le")
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += counts[i]
+			le := append(append([]string{}, values...), formatFloat(upper))
+			if err := writeSample(w, h.name+"_bucket", labels, le, float64(cumulative)); err != nil {
+				return err
+			}
+		}
+		le := append(append([]string{}, values...), "+Inf")
+		if err := writeSample(w, h.name+"_bucket", labels, le, float64(count)); err != nil {
+			return err
+		}
+		if err := writeSample(w, h.name+"_sum", h.labels, values, sum); err != nil {
+			return err
+		}
+		return writeSample(w, h.name+"_count", h.labels, values, float64(count))
+	})
+}
+
+// Sample is a single value of a gauge computed at scrape time.
+type Sample struct {
+	Values []string // label values, in the order of the gauge's label names
+	Value  float64
+}
+
+// GaugeFunc is a gauge whose samples are computed whenever metrics are written.
+type GaugeFunc struct {
+	name   string
+	help   string
+	labels []string
+	fn     func() []Sample
+}
+
+// GaugeFunc creates and registers a gauge computed by fn.
+func (r *Registry) GaugeFunc(name, help string, fn func() []Sample, labels ...string) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, labels: labels, fn: fn}
+	r.register(g)
+	return g
+}
+
+// Gauge creates and registers a gauge without labels computed by fn.
+func (r *Registry) Gauge(name, help string, fn func() float64) *GaugeFunc {
+	return r.GaugeFunc(name, help, func() []Sample {
+		return []Sample{{Value: fn()}}
+	})
+}
+
+func (g *GaugeFunc) write(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, escapeHelp(g.help), g.name); err != nil {
+		return err
+	}
+	for _, s := range g.fn() {
+		if err := writeSample(w, g.name, g.labels, s.Values, s.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeSample(w io.Writer, name string, labels []string, values []string, value float64) error {
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(l)
+			b.WriteString(`="`)
+			b.WriteString(escapeLabel(values[i]))
+			b.WriteByte('"')
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(value))
+	b.WriteByte('\n')
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func init() {
+	Default.Gauge("go_goroutines", "Number of goroutines that currently exist.", func() float64 {
+		return float64(runtime.NumGoroutine())
+	})
+	Default.GaugeFunc("go_memstats_bytes", "Memory statistics from the Go runtime, by kind.", func() []Sample {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		return []Sample{
+			{Values: []string{"heap_alloc"}, Value: float64(m.HeapAlloc)},
+			{Values: []string{"heap_inuse"}, Value: float64(m.HeapInuse)},
+			{Values: []string{"sys"}, Value: float64(m.Sys)},
+		}
+	}, "kind")
+	Default.Gauge("go_gc_cycles", "Number of completed garbage collection cycles.", func() float64 {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		return float64(m.NumGC)
+	})
+}
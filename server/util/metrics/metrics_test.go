@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	r := &Registry{}
+	c := r.CounterVec("test_messages_total", "Messages by type.", "type")
+	c.With("chat").Inc()
+	c.With("chat").Add(2)
+	c.With(`a"b`).Inc()
+
+	h := r.HistogramVec("test_duration_seconds", "Durations.", []float64{1, 0.1}, "type")
+	h.With("draw").Observe(0.05)
+	h.With("draw").Observe(0.5)
+	h.With("draw").Observe(5)
+
+	r.GaugeFunc("test_rooms", "Rooms by phase.", func() []Sample {
+		return []Sample{{Values: []string{"lobby"}, Value: 2}}
+	}, "phase")
+	r.Gauge("test_players", "Players.\nSecond line.", func() float64 { return 7 })
+
+	var b strings.Builder
+	assert.NoError(t, r.Write(&b))
+	assert.Equal(t, `# HELP test_messages_total Messages by type.
+# TYPE test_messages_total counter
+test_messages_total{type="a\"b"} 1
+test_messages_total{type="chat"} 3
+# HELP test_duration_seconds Durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{type="draw",le="0.1"} 1
+test_duration_seconds_bucket{type="draw",le="1"} 2
+test_duration_seconds_bucket{type="draw",le="+Inf"} 3
+test_duration_seconds_sum{type="draw"} 5.55
+test_duration_seconds_count{type="draw"} 3
+# HELP test_rooms Rooms by phase.
+# TYPE test_rooms gauge
+test_rooms{phase="lobby"} 2
+# HELP test_players Players.\nSecond line.
+# TYPE test_players gauge
+test_players 7
+`, b.String())
+}
+
+func TestWrongLabelCount(t *testing.T) {
+	c := (&Registry{}).CounterVec("test_total", "Test.", "a", "b")
+	assert.Panics(t, func() { c.With("x") })
+}
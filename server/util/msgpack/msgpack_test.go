@@ -0,0 +1,105 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundTrip(t *testing.T) {
+	values := []any{
+		nil,
+		true,
+		false,
+		int64(0),
+		int64(127),
+		int64(128),
+		int64(-1),
+		int64(-32),
+		int64(-33),
+		int64(math.MinInt8 - 1),
+		int64(math.MaxUint16 + 1),
+		int64(math.MinInt32 - 1),
+		int64(math.MaxInt64),
+		int64(math.MinInt64),
+		uint64(math.MaxUint64),
+		1.5,
+		"",
+		"hello",
+		strings.Repeat("x", 31),
+		strings.Repeat("x", 32),
+		strings.Repeat("x", 300),
+		strings.Repeat("x", 70000),
+		[]byte{1, 2, 3},
+		[]any{},
+		[]any{int64(1), "two", nil, []any{true}},
+		map[string]any{},
+		map[string]any{"a": int64(1), "b": map[string]any{"c": []any{"d"}}},
+	}
+
+	for _, v := range values {
+		data, err := Marshal(v)
+		assert.NoError(t, err)
+		got, err := Unmarshal(data)
+		assert.NoError(t, err)
+		assert.Equal(t, v, got)
+	}
+}
+
+func TestLargeCollections(t *testing.T) {
+	a := make([]any, 70000)
+	m := make(map[string]any)
+	for i := range a {
+		a[i] = int64(i)
+	}
+	for i := 0; i < 20; i++ {
+		m[strings.Repeat("k", i+1)] = int64(i)
+	}
+
+	for _, v := range []any{a, m} {
+		data, err := Marshal(v)
+		assert.NoError(t, err)
+		got, err := Unmarshal(data)
+		assert.NoError(t, err)
+		assert.Equal(t, v, got)
+	}
+}
+
+func TestKnownEncoding(t *testing.T) {
+	data, err := Marshal(map[string]any{"compact": true, "schema": 0})
+	assert.NoError(t, err)
+	// example from https://msgpack.org
+	want := []byte{0x82, 0xa7, 'c', 'o', 'm', 'p', 'a', 'c', 't', 0xc3, 0xa6, 's', 'c', 'h', 'e', 'm', 'a', 0x00}
+	assert.True(t, bytes.Equal(want, data), "got % x", data)
+}
+
+func TestJSONNumbers(t *testing.T) {
+	data, err := Marshal([]any{json.Number("42"), json.Number("-0.5")})
+	assert.NoError(t, err)
+	got, err := Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(42), -0.5}, got)
+}
+
+func TestInvalid(t *testing.T) {
+	_, err := Marshal(struct{}{})
+	assert.Error(t, err)
+
+	invalid := [][]byte{
+		{},
+		{0xc1},             // never used
+		{0xa3, 'a'},        // short string
+		{0x92, 0x01},       // short array
+		{0x81, 0x01, 0x01}, // non-string key
+		{0xdd, 0xff, 0xff, 0xff, 0xff},
+		{0x01, 0x02}, // trailing data
+	}
+	for _, data := range invalid {
+		_, err := Unmarshal(data)
+		assert.Error(t, err, "% x should be invalid", data)
+	}
+}
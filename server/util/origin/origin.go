@@ -0,0 +1,117 @@
+// Package origin matches the Origin header of browser requests against an
+// allow-list.
+package origin
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// pattern is a parsed allow-list entry.
+type pattern struct {
+	scheme   string // "" for any scheme
+	host     string // lower case; for wildcards, the parent domain
+	wildcard bool   // true if subdomains of host match, but host itself does not
+	port     string // "" for no port, "*" for any port
+}
+
+// parsePattern parses an allow-list entry: "*" for any origin, or
+// [scheme://]host[:port], where host may start with "*." to match any
+// subdomain and port may be "*" to match any port.
+func parsePattern(s string) (pattern, error) {
+	var p pattern
+	rest := s
+	if i := strings.Index(rest, "://"); i >= 0 {
+		p.scheme, rest = strings.ToLower(rest[:i]), rest[i+3:]
+		if p.scheme == "" {
+			return p, fmt.Errorf("invalid origin %q: empty scheme", s)
+		}
+	}
+	if strings.ContainsAny(rest, "/?#@ ,") {
+		return p, fmt.Errorf("invalid origin %q: only scheme, host and port are allowed", s)
+	}
+
+	if i := strings.LastIndex(rest, ":"); i >= 0 && !strings.HasSuffix(rest, "]") {
+		rest, p.port = rest[:i], rest[i+1:]
+		if p.port == "" {
+			return p, fmt.Errorf("invalid origin %q: empty port", s)
+		}
+	}
+	if strings.HasPrefix(rest, "*.") {
+		p.wildcard = true
+		rest = rest[2:]
+	}
+	if rest == "" || strings.Contains(rest, "*") {
+		return p, fmt.Errorf("invalid origin %q: bad host", s)
+	}
+	p.host = strings.ToLower(rest)
+	return p, nil
+}
+
+func (p pattern) match(scheme, host, port string) bool {
+	if p.scheme != "" && p.scheme != scheme {
+		return false
+	}
+	if p.port != "*" && p.port != port {
+		return false
+	}
+	if p.wildcard {
+		return strings.HasSuffix(host, "."+p.host)
+	}
+	return host == p.host
+}
+
+// AllowList decides which origins may use the server.
+type AllowList struct {
+	any      bool
+	patterns []pattern
+}
+
+// NewAllowList parses the given entries. An empty list only allows
+// same-origin requests.
+func NewAllowList(entries []string) (*AllowList, error) {
+	l := &AllowList{}
+	for _, e := range entries {
+		if e == "*" {
+			l.any = true
+			continue
+		}
+		p, err := parsePattern(e)
+		if err != nil {
+			return nil, err
+		}
+		l.patterns = append(l.patterns, p)
+	}
+	return l, nil
+}
+
+// Allowed returns true if a request from origin to host may proceed. Requests
+// without an origin are not from browsers and are always allowed, as are
+// same-origin requests.
+func (l *AllowList) Allowed(origin string, host string) bool {
+	if origin == "" || l.any {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, host) {
+		return true
+	}
+
+	hostname := strings.ToLower(u.Hostname())
+	if strings.Contains(u.Host, "[") {
+		// keep the brackets of IPv6 addresses so they compare like patterns
+		hostname = "[" + hostname + "]"
+	}
+	scheme := strings.ToLower(u.Scheme)
+	for _, p := range l.patterns {
+		if p.match(scheme, hostname, u.Port()) {
+			return true
+		}
+	}
+	return false
+}
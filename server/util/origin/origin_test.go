@@ -0,0 +1,58 @@
+package origin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowList(t *testing.T) {
+	l, err := NewAllowList([]string{
+		"https://example.com",
+		"*.games.test",
+		"http://localhost:*",
+		"http://[::1]:*",
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"", true},
+		{"https://example.com", true},
+		{"https://EXAMPLE.com", true},
+		{"http://example.com", false},
+		{"https://example.com:8443", false},
+		{"https://evil-example.com", false},
+		{"https://a.games.test", true},
+		{"http://a.b.games.test", true},
+		{"https://games.test", false},
+		{"https://agames.test", false},
+		{"http://localhost:3000", true},
+		{"http://localhost", true},
+		{"https://localhost:3000", false},
+		{"http://[::1]:3000", true},
+		{"null", false},
+		{"https://server.test", true}, // same origin
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.allowed, l.Allowed(tt.origin, "server.test"), tt.origin)
+	}
+
+	all, err := NewAllowList([]string{"*"})
+	assert.NoError(t, err)
+	assert.True(t, all.Allowed("https://anything.test", "server.test"))
+
+	none, err := NewAllowList(nil)
+	assert.NoError(t, err)
+	assert.False(t, none.Allowed("https://example.com", "server.test"))
+	assert.True(t, none.Allowed("http://server.test", "server.test"))
+}
+
+func TestInvalidPatterns(t *testing.T) {
+	for _, p := range []string{"", "https://example.com/path", "https://*", "example.com:", "://example.com", "a.*.example.com", "a,b"} {
+		_, err := NewAllowList([]string{p})
+		assert.Error(t, err, p)
+	}
+}
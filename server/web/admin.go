@@ -0,0 +1,128 @@
+package web
+
+import (
+	"cardgame/deck"
+	"cardgame/game"
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requireAdmin aborts requests without the given bearer token. The admin API
+// is disabled if the token is empty.
+func requireAdmin(adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if adminToken == "" {
+			c.AbortWithStatusJSON(404, gin.H{"error": "not found"})
+			return
+		}
+
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			c.AbortWithStatusJSON(401, gin.H{"error": "invalid admin token"})
+			return
+		}
+	}
+}
+
+func InitAdminApi(e *gin.RouterGroup, adminToken string) *gin.RouterGroup {
+	e.Use(requireAdmin(adminToken))
+
+	e.GET("/rooms", AdminGetRooms)
+	e.GET("/room/:room", AdminGetRoom)
+	e.DELETE("/room/:room", AdminCloseRoom)
+	e.DELETE("/room/:room/player/:player", AdminKickPlayer)
+	e.POST("/announce", AdminAnnounce)
+	e.POST("/decks/reload", AdminReloadDecks)
+
+	return e
+}
+
+func AdminGetRooms(c *gin.Context) {
+	all := game.HubMain.AllRooms()
+	rooms := make([]any, 0, len(all))
+	for _, r := range all {
+		state, err := r.State()
+		if err != nil {
+			c.AbortWithStatusJSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		rooms = append(rooms, state)
+	}
+	c.JSON(200, gin.H{
+		"rooms": rooms,
+		"count": len(rooms),
+	})
+}
+
+func AdminGetRoom(c *gin.Context) {
+	r := game.HubMain.Room(c.Param("room"))
+	if r == nil {
+		c.AbortWithStatusJSON(404, gin.H{"error": "room not found"})
+		return
+	}
+
+	inspection, err := r.Inspect()
+	if err != nil {
+		c.AbortWithStatusJSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"room": inspection})
+}
+
+func AdminCloseRoom(c *gin.Context) {
+	id := c.Param("room")
+	reason := c.DefaultQuery("reason", "closed by an administrator")
+	if err := game.HubMain.CloseRoom(id, reason); err != nil {
+		c.AbortWithStatusJSON(404, gin.H{"error": "room not found"})
+		return
+	}
+	c.JSON(200, gin.H{"closed": id})
+}
+
+func AdminKickPlayer(c *gin.Context) {
+	r := game.HubMain.Room(c.Param("room"))
+	if r == nil {
+		c.AbortWithStatusJSON(404, gin.H{"error": "room not found"})
+		return
+	}
+
+	id := c.Param("player")
+	if err := r.Kick(id, c.Query("ban") == "true"); err != nil {
+		var e *game.Error
+		switch {
+		case errors.As(err, &e) && e.Code == game.ErrorRoomNotFound:
+			c.AbortWithStatusJSON(404, gin.H{"error": "room not found"})
+		case errors.As(err, &e) && e.Code == game.ErrorPlayerNotFound:
+			c.AbortWithStatusJSON(404, gin.H{"error": "player not found"})
+		default:
+			c.AbortWithStatusJSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(200, gin.H{"kicked": id})
+}
+
+func AdminAnnounce(c *gin.Context) {
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || strings.TrimSpace(body.Message) == "" {
+		c.AbortWithStatusJSON(400, gin.H{"error": "message is required"})
+		return
+	}
+
+	recipients := game.HubMain.Announce(body.Message)
+	c.JSON(200, gin.H{"recipients": recipients})
+}
+
+func AdminReloadDecks(c *gin.Context) {
+	count, err := deck.ReloadDecks()
+	if err != nil {
+		c.AbortWithStatusJSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"decks": count})
+}
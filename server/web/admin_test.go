@@ -0,0 +1,88 @@
+package web
+
+import (
+	"cardgame/config"
+	"cardgame/game"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func adminRequest(t *testing.T, api *gin.Engine, method string, path string, body string, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	api.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminAuth(t *testing.T) {
+	w := adminRequest(t, initTestApiWith(t, config.Default()), "GET", "/api/admin/rooms", "", "anything")
+	assert.Equal(t, 404, w.Code, "admin api is disabled without a token")
+
+	api := initTestApi(t)
+	w = adminRequest(t, api, "GET", "/api/admin/rooms", "", "")
+	assert.Equal(t, 401, w.Code)
+	w = adminRequest(t, api, "GET", "/api/admin/rooms", "", "wrong")
+	assert.Equal(t, 401, w.Code)
+	w = adminRequest(t, api, "GET", "/api/admin/rooms", "", "secret")
+	assert.Equal(t, 200, w.Code)
+}
+
+func TestAdminRooms(t *testing.T) {
+	api := initTestApi(t)
+
+	private := makePrivateRoom(t, api, "hunter2")
+
+	w := adminRequest(t, api, "GET", "/api/admin/rooms", "", "secret")
+	assert.Equal(t, 200, w.Code)
+	var rooms struct {
+		Rooms []*game.Room `json:"rooms"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rooms))
+	ids := []string{}
+	for _, r := range rooms.Rooms {
+		ids = append(ids, r.Id)
+	}
+	assert.Contains(t, ids, private.Id, "private rooms are listed")
+
+	w = adminRequest(t, api, "GET", "/api/admin/room/"+private.Id, "", "secret")
+	assert.Equal(t, 200, w.Code)
+	var inspection struct {
+		Room struct {
+			Private  bool  `json:"private"`
+			DrawPile []any `json:"drawPile"`
+		} `json:"room"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &inspection))
+	assert.True(t, inspection.Room.Private)
+	assert.NotNil(t, inspection.Room.DrawPile)
+
+	w = adminRequest(t, api, "DELETE", "/api/admin/room/"+private.Id+"/player/p_missing", "", "secret")
+	assert.Equal(t, 404, w.Code)
+
+	w = adminRequest(t, api, "DELETE", "/api/admin/room/"+private.Id, "", "secret")
+	assert.Equal(t, 200, w.Code)
+	assert.Nil(t, game.HubMain.Room(private.Id))
+	w = adminRequest(t, api, "GET", "/api/admin/room/"+private.Id, "", "secret")
+	assert.Equal(t, 404, w.Code)
+}
+
+func TestAdminAnnounce(t *testing.T) {
+	api := initTestApi(t)
+
+	w := adminRequest(t, api, "POST", "/api/admin/announce", `{"message":""}`, "secret")
+	assert.Equal(t, 400, w.Code)
+
+	w = adminRequest(t, api, "POST", "/api/admin/announce", `{"message":"restarting in 5 minutes"}`, "secret")
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), `"recipients"`)
+}
@@ -2,12 +2,18 @@ package web
 
 import (
 	"cardgame/build"
+	"cardgame/config"
+	"cardgame/util/origin"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func InitApi(e *gin.RouterGroup) *gin.RouterGroup {
+func InitApi(e *gin.RouterGroup, cfg *config.Config) *gin.RouterGroup {
+	return initApi(e, cfg, allowList(cfg))
+}
+
+func initApi(e *gin.RouterGroup, cfg *config.Config, origins *origin.AllowList) *gin.RouterGroup {
 	e.GET("/info", func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"commit":    build.Commit(),
@@ -20,11 +26,13 @@ func InitApi(e *gin.RouterGroup) *gin.RouterGroup {
 	e.GET("/rooms", GetRooms)
 	e.GET("/room/:room", GetRoom)
 	e.POST("/room", CreateRoom)
-	e.GET("/ws/:room", ServeWS)
+	e.GET("/ws/:room", ServeWS(newUpgrader(origins)))
 
 	e.GET("/decks", GetDecks)
 	e.GET("/deck/:id", GetDeck)
 
+	InitAdminApi(e.Group("/admin"), cfg.AdminToken)
+
 	e.GET("/me", GetUser)
 	e.POST("/me", CreateUser)
 	e.PUT("/me", UpdateUser)
@@ -1,6 +1,7 @@
 package web
 
 import (
+	"cardgame/config"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -10,10 +11,20 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// testAdminToken is the admin token of the API returned by initTestApi.
+const testAdminToken = "secret"
+
 func initTestApi(t *testing.T) *gin.Engine {
+	t.Helper()
+	cfg := config.Default()
+	cfg.AdminToken = testAdminToken
+	return initTestApiWith(t, cfg)
+}
+
+func initTestApiWith(t *testing.T, cfg *config.Config) *gin.Engine {
 	t.Helper()
 	e := gin.New()
-	InitApi(e.Group("/api"))
+	InitApi(e.Group("/api"), cfg)
 	return e
 }
 
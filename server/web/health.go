@@ -0,0 +1,33 @@
+package web
+
+import (
+	"cardgame/game"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetHealthz reports whether the server is running.
+func GetHealthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// GetReadyz reports whether the server accepts new players. It fails once
+// the server is shutting down.
+func GetReadyz(c *gin.Context) {
+	if game.HubMain.Draining() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// rejectDraining aborts the request with 503 if the server is shutting down.
+// It returns true if the request was aborted.
+func rejectDraining(c *gin.Context) bool {
+	if !game.HubMain.Draining() {
+		return false
+	}
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+	return true
+}
@@ -0,0 +1,25 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	e := gin.New()
+	e.GET("/healthz", GetHealthz)
+	e.GET("/readyz", GetReadyz)
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		e.ServeHTTP(w, req)
+
+		assert.Equal(t, 200, w.Code, path)
+		assert.JSONEq(t, `{"status":"ok"}`, w.Body.String(), path)
+	}
+}
@@ -0,0 +1,14 @@
+package web
+
+import (
+	"cardgame/util/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMetrics serves metrics in the Prometheus text exposition format.
+func GetMetrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(200)
+	metrics.Default.Write(c.Writer)
+}
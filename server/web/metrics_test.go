@@ -0,0 +1,35 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	e := gin.New()
+	e.GET("/metrics", GetMetrics)
+	makePublicRoom(t, initTestApi(t))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	e.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+	body := w.Body.String()
+	assert.Contains(t, body, "# TYPE cardgame_rooms gauge")
+	assert.Regexp(t, `cardgame_rooms\{phase="lobby"\} [1-9]`, body)
+	assert.Contains(t, body, `cardgame_messages_in_total{type="chat"}`)
+	assert.Contains(t, body, `cardgame_handler_duration_seconds_bucket{type="draw",le="+Inf"}`)
+	assert.Contains(t, body, `cardgame_errors_total{code="not_owner"}`)
+	assert.Contains(t, body, "cardgame_websockets ")
+	assert.Contains(t, body, `cardgame_queue{stat="queued"}`)
+	assert.NotContains(t, body, `cardgame_queue{stat="dropped"}`, "totals are counters")
+	assert.Contains(t, body, "# TYPE cardgame_queue_dropped_total counter\ncardgame_queue_dropped_total ")
+	assert.Contains(t, body, "# TYPE cardgame_queue_overflows_total counter")
+	assert.Contains(t, body, "go_goroutines ")
+}
@@ -7,20 +7,26 @@ import (
 )
 
 func GetRooms(c *gin.Context) {
-	rooms := []*game.Room{}
-	for _, r := range game.HubMain.Rooms {
+	all := game.HubMain.AllRooms()
+	rooms := []any{}
+	for _, r := range all {
 		if r.IsPrivate() {
 			continue
 		}
-		rooms = append(rooms, r)
+		state, err := r.State()
+		if err != nil {
+			c.AbortWithStatusJSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		rooms = append(rooms, state)
 	}
 
 	c.JSON(200, gin.H{
 		"rooms": rooms,
 		"count": gin.H{
 			"public":  len(rooms),
-			"private": len(game.HubMain.Rooms) - len(rooms),
-			"total":   len(game.HubMain.Rooms),
+			"private": len(all) - len(rooms),
+			"total":   len(all),
 		},
 	})
 }
@@ -28,8 +34,8 @@ func GetRooms(c *gin.Context) {
 func GetRoom(c *gin.Context) {
 	id := c.Param("room")
 	password := c.Request.Header.Get("X-Password")
-	r, ok := game.HubMain.Rooms[id]
-	if !ok || (r.IsPrivate() && password == "") {
+	r := game.HubMain.Room(id)
+	if r == nil || (r.IsPrivate() && password == "") {
 		c.AbortWithStatusJSON(404, gin.H{"error": "room not found"})
 		return
 	}
@@ -38,12 +44,30 @@ func GetRoom(c *gin.Context) {
 		return
 	}
 
-	c.JSON(200, gin.H{"room": r})
+	respondRoom(c, r)
 }
 
 func CreateRoom(c *gin.Context) {
+	if rejectDraining(c) {
+		return
+	}
+	if game.HubMain.RoomLimitReached() {
+		c.AbortWithStatusJSON(503, gin.H{"error": "too many rooms"})
+		return
+	}
+
 	password := c.Request.Header.Get("X-Password")
 	r := game.HubMain.NewRoom(password)
 
-	c.JSON(200, gin.H{"room": r})
+	respondRoom(c, r)
+}
+
+// respondRoom responds with the state of the room, taken under its lock.
+func respondRoom(c *gin.Context, r *game.Room) {
+	state, err := r.State()
+	if err != nil {
+		c.AbortWithStatusJSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"room": state})
 }
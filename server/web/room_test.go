@@ -1,11 +1,15 @@
 package web
 
 import (
+	"cardgame/client"
+	"cardgame/config"
 	"cardgame/game"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -52,7 +56,7 @@ func TestPublicRoom(t *testing.T) {
 	api := initTestApi(t)
 	rm := makePublicRoom(t, api)
 
-	assert.Contains(t, game.HubMain.Rooms, rm.Id, "should contain public room")
+	assert.NotNil(t, game.HubMain.Room(rm.Id), "should contain public room")
 
 	type response struct {
 		Room  *game.Room `json:"room"`
@@ -66,7 +70,7 @@ func TestPublicRoom(t *testing.T) {
 	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &r))
 	assert.Equal(t, rm.Id, r.Room.Id, "should be able to get correct room")
 
-	delete(game.HubMain.Rooms, rm.Id)
+	game.HubMain.CloseRoom(rm.Id, "test ended")
 }
 
 func TestRoomList(t *testing.T) {
@@ -86,8 +90,8 @@ func TestRoomList(t *testing.T) {
 	assert.Contains(t, r.Rooms, pubRoom, "should contain public room")
 	assert.NotContains(t, r.Rooms, privRoom, "should not contain private room")
 
-	delete(game.HubMain.Rooms, pubRoom.Id)
-	delete(game.HubMain.Rooms, privRoom.Id)
+	game.HubMain.CloseRoom(pubRoom.Id, "test ended")
+	game.HubMain.CloseRoom(privRoom.Id, "test ended")
 }
 
 func TestPrivateRoom(t *testing.T) {
@@ -95,7 +99,7 @@ func TestPrivateRoom(t *testing.T) {
 	password := "correct horse battery staple"
 	rm := makePrivateRoom(t, api, password)
 
-	assert.Contains(t, game.HubMain.Rooms, rm.Id, "should contain private room")
+	assert.NotNil(t, game.HubMain.Room(rm.Id), "should contain private room")
 
 	type response struct {
 		Error string     `json:"error"`
@@ -123,5 +127,119 @@ func TestPrivateRoom(t *testing.T) {
 	assert.Equal(t, 200, w.Code, "should be able to get private room with correct password")
 	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &r))
 
-	delete(game.HubMain.Rooms, rm.Id)
+	game.HubMain.CloseRoom(rm.Id, "test ended")
+}
+
+// dialRoom connects a client to the test server for the given room.
+func dialRoom(t *testing.T, srv *httptest.Server, roomId string, opts client.Options) *client.Client {
+	t.Helper()
+	c, err := client.Dial(client.RoomURL(srv.URL, roomId), opts)
+	if !assert.NoError(t, err, "should be able to connect") {
+		t.FailNow()
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// waitFor returns the next message of type T received by c, skipping others.
+func waitFor[T any](t *testing.T, c *client.Client, match func(*T) bool) *T {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case m, ok := <-c.Messages():
+			if !ok {
+				t.Fatalf("connection closed while waiting for %T", *new(T))
+			}
+			if m, ok := any(m).(*T); ok && (match == nil || match(m)) {
+				return m
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %T", *new(T))
+		}
+	}
+}
+
+func TestRoomWebsocket(t *testing.T) {
+	router := NewRouter(config.Default())
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	password := "correct horse battery staple"
+	rm := makePrivateRoom(t, router, password)
+	defer game.HubMain.CloseRoom(rm.Id, "test ended")
+
+	alice := dialRoom(t, srv, rm.Id, client.Options{})
+	assert.Contains(t, alice.Hello.Features, "delta")
+
+	var e *client.Error
+	err := alice.Join(rm.Id, "wrong password", "alice")
+	assert.True(t, errors.As(err, &e), "should not be able to join with wrong password")
+	assert.Equal(t, game.ErrorIncorrectPassword, e.Code)
+
+	assert.NoError(t, alice.Join(rm.Id, password, "alice"))
+	assert.NotEmpty(t, alice.PlayerId(), "should receive a session")
+	state, err := alice.Room()
+	assert.NoError(t, err)
+	assert.Equal(t, rm.Id, state.Id, "should receive the room state")
+
+	bob := dialRoom(t, srv, rm.Id, client.Options{Reconnect: true})
+	assert.NoError(t, bob.Join(rm.Id, password, "bob"))
+	join := waitFor(t, alice, func(m *game.ServerJoin) bool { return m.Id == bob.PlayerId() })
+	assert.Equal(t, "bob", join.Player.Name)
+
+	err = bob.Kick(alice.PlayerId(), false)
+	assert.True(t, errors.As(err, &e), "only the owner should be able to kick")
+	assert.Equal(t, game.ErrorNotOwner, e.Code)
+
+	assert.NoError(t, bob.Chat("hello"))
+	chat := waitFor(t, alice, func(m *game.ServerChat) bool { return !m.System })
+	assert.Equal(t, bob.PlayerId(), chat.PlayerId)
+	assert.Equal(t, "hello", chat.Message)
+
+	assert.NoError(t, bob.Ready(true))
+	waitFor(t, alice, func(m *game.ServerReady) bool { return m.PlayerId == bob.PlayerId() })
+	state, err = alice.Room()
+	assert.NoError(t, err)
+	assert.Len(t, state.Players, 2)
+	for _, p := range state.Players {
+		assert.Equal(t, p.Id == bob.PlayerId(), p.Ready, "state should be patched")
+	}
+
+	// drop bob's connection; the client reconnects and the chat message sent
+	// in between is replayed
+	bob.Socket().Close()
+	assert.NoError(t, alice.Chat("welcome back"))
+	waitFor(t, bob, func(m *game.ServerChat) bool { return m.Message == "welcome back" })
+	assert.NoError(t, bob.Chat("thanks"), "should be connected again")
+
+	bobId := bob.PlayerId()
+	assert.NoError(t, bob.Leave())
+	leave := waitFor(t, alice, (func(*game.ServerLeave) bool)(nil))
+	assert.Equal(t, bobId, leave.Id)
+}
+
+func TestBanNewConnection(t *testing.T) {
+	router := NewRouter(config.Default())
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	rm := makePublicRoom(t, router)
+	defer game.HubMain.CloseRoom(rm.Id, "test ended")
+
+	alice := dialRoom(t, srv, rm.Id, client.Options{})
+	assert.NoError(t, alice.Join(rm.Id, "", "alice"))
+	bob := dialRoom(t, srv, rm.Id, client.Options{})
+	assert.NoError(t, bob.Join(rm.Id, "", "bob"))
+
+	assert.NoError(t, alice.Kick(bob.PlayerId(), true))
+	kick := waitFor(t, bob, (func(*game.ServerKick) bool)(nil))
+	assert.True(t, kick.Banned)
+
+	// a new connection gets a new session, but comes from the same address
+	again := dialRoom(t, srv, rm.Id, client.Options{})
+	var e *client.Error
+	err := again.Join(rm.Id, "", "bob")
+	assert.True(t, errors.As(err, &e), "banned player should not be able to rejoin")
+	assert.Equal(t, game.ErrorBanned, e.Code)
 }
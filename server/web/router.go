@@ -0,0 +1,53 @@
+package web
+
+import (
+	"cardgame/config"
+	"cardgame/util/logging"
+	"cardgame/util/origin"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// NewRouter returns the HTTP handler of the server: the API under /api,
+// metrics and health checks.
+func NewRouter(cfg *config.Config) *gin.Engine {
+	r := gin.Default()
+	r.SetTrustedProxies([]string{})
+
+	origins := allowList(cfg)
+	c := cors.DefaultConfig()
+	c.AllowOriginFunc = func(o string) bool {
+		// same-origin requests never get here
+		if !origins.Allowed(o, "") {
+			logging.Default.Debug("rejected cors origin", "origin", o)
+			return false
+		}
+		return true
+	}
+	c.AllowHeaders = []string{"Origin", "Content-Type", "X-Password"}
+	r.Use(cors.New(c))
+
+	initApi(r.Group("/api"), cfg, origins)
+	r.GET("/metrics", GetMetrics)
+	r.GET("/healthz", GetHealthz)
+	r.GET("/readyz", GetReadyz)
+
+	r.Use(func(c *gin.Context) {
+		c.AbortWithStatusJSON(404, gin.H{"error": "not found"})
+	})
+
+	return r
+}
+
+// allowList returns the origins allowed by the configuration. The
+// configuration is validated when it is loaded; if it is invalid anyway, only
+// same-origin requests are allowed.
+func allowList(cfg *config.Config) *origin.AllowList {
+	origins, err := origin.NewAllowList(cfg.AllowedOrigins)
+	if err != nil {
+		logging.Default.Error("invalid allowed origins", "error", err)
+		origins, _ = origin.NewAllowList(nil)
+	}
+	return origins
+}
@@ -2,32 +2,47 @@ package web
 
 import (
 	"cardgame/game"
+	"cardgame/util/logging"
+	"cardgame/util/origin"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	EnableCompression: true,
-	ReadBufferSize:    1024,
-	WriteBufferSize:   1024,
-	CheckOrigin: func(r *http.Request) bool {
-		// TODO: validate origin
-		return true
-	},
+// newUpgrader returns a websocket upgrader that only accepts connections from
+// the given origins.
+func newUpgrader(origins *origin.AllowList) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		EnableCompression: true,
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		Subprotocols:      game.Subprotocols,
+		CheckOrigin: func(r *http.Request) bool {
+			o := r.Header.Get("Origin")
+			if !origins.Allowed(o, r.Host) {
+				logging.Default.Warn("rejected websocket origin", "origin", o, "host", r.Host)
+				return false
+			}
+			return true
+		},
+	}
 }
 
-func upgrade(c *gin.Context) (*websocket.Conn, error) {
-	return upgrader.Upgrade(c.Writer, c.Request, nil)
-}
+// ServeWS returns a handler that upgrades requests to websocket connections
+// for new players.
+func ServeWS(upgrader *websocket.Upgrader) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rejectDraining(c) {
+			return
+		}
 
-func ServeWS(c *gin.Context) {
-	conn, err := upgrade(c)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			// the upgrader has already written an error response
+			return
+		}
 
-	game.NewPlayer(conn)
+		game.HubMain.NewPlayer(conn)
+	}
 }
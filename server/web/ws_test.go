@@ -0,0 +1,48 @@
+package web
+
+import (
+	"cardgame/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrigins(t *testing.T) {
+	cfg := config.Default()
+	cfg.AllowedOrigins = []string{"https://*.cardgame.test"}
+	srv := httptest.NewServer(NewRouter(cfg))
+	defer srv.Close()
+
+	get := func(origin string) *http.Response {
+		req, _ := http.NewRequest("GET", srv.URL+"/api/rooms", nil)
+		req.Header.Set("Origin", origin)
+		res, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		res.Body.Close()
+		return res
+	}
+	res := get("https://play.cardgame.test")
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "https://play.cardgame.test", res.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, 403, get("https://evil.test").StatusCode)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/ws/r_any"
+	dial := func(origin string) (*websocket.Conn, *http.Response, error) {
+		return websocket.DefaultDialer.Dial(url, http.Header{"Origin": {origin}})
+	}
+	_, res, err := dial("https://evil.test")
+	assert.Error(t, err)
+	assert.Equal(t, 403, res.StatusCode)
+
+	conn, _, err := dial("https://play.cardgame.test")
+	assert.NoError(t, err)
+	conn.Close()
+
+	conn, _, err = websocket.DefaultDialer.Dial(url, nil)
+	assert.NoError(t, err, "clients without an origin are allowed")
+	conn.Close()
+}